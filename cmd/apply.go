@@ -35,11 +35,15 @@ import (
 func init() {
 	rootCommand.AddCommand(applyCmd)
 
-	applyCmd.Flags().StringArrayP("file", "f", []string{""}, "A number of init.yaml plan files")
+	applyCmd.Flags().StringArrayP("file", "f", []string{""}, "A number of init.yaml plan files, given as a path, - for stdin, a URL or git::<repo>//<path>?ref=<ref>")
 	applyCmd.Flags().Bool("skip-sealedsecrets", false, "Skip SealedSecrets installation")
 	applyCmd.Flags().Bool("skip-minio", false, "Skip Minio installation")
 	applyCmd.Flags().Bool("skip-create-secrets", false, "Skip creating secrets")
 	applyCmd.Flags().Bool("print-plan", false, "Print merged plan and exit")
+	applyCmd.Flags().Bool("explain", false, "Annotate each field printed by --print-plan with the file that set it")
+	applyCmd.Flags().Bool("strict-env", false, "Fail when a plan file refers to an undefined environment variable")
+	applyCmd.Flags().String("profile", "", "Merge the named overlay from the profiles of the plan")
+	applyCmd.Flags().Bool("check-registry", false, "Log in to the registry to check that the credentials are valid and can push images")
 }
 
 var applyCmd = &cobra.Command{
@@ -70,6 +74,22 @@ func runApplyCommandE(command *cobra.Command, _ []string) error {
 	if err != nil {
 		return err
 	}
+	explain, err := command.Flags().GetBool("explain")
+	if err != nil {
+		return err
+	}
+	strictEnv, err := command.Flags().GetBool("strict-env")
+	if err != nil {
+		return err
+	}
+	profile, err := command.Flags().GetString("profile")
+	if err != nil {
+		return err
+	}
+	checkRegistry, err := command.Flags().GetBool("check-registry")
+	if err != nil {
+		return err
+	}
 
 	prefs.SkipMinio, err = command.Flags().GetBool("skip-minio")
 	if err != nil {
@@ -84,35 +104,36 @@ func runApplyCommandE(command *cobra.Command, _ []string) error {
 		return err
 	}
 
-	if len(files) == 0 {
-		return fmt.Errorf("provide one or more --file arguments")
+	if explain && !printPlan {
+		return fmt.Errorf("--explain can only be used with --print-plan")
 	}
 
-	plans := []types.Plan{}
-	for _, yamlFile := range files {
-
-		yamlBytes, err := ioutil.ReadFile(yamlFile)
-		if err != nil {
-			return fmt.Errorf("loading --file %s gave error: %s", yamlFile, err.Error())
-		}
-
-		plan := types.Plan{}
-		if err := yaml.Unmarshal(yamlBytes, &plan); err != nil {
-			return fmt.Errorf("unmarshal of --file %s gave error: %s", yamlFile, err.Error())
-		}
-
-		log.Printf("%s loaded\n", yamlFile)
-		plans = append(plans, plan)
+	planFiles, err := readPlanFiles(files, strictEnv)
+	if err != nil {
+		return err
 	}
 
-	log.Printf("Loaded %d plan(s)\n", len(files))
-	planMerged, err := types.MergePlans(plans)
+	planMerged, err := types.MergePlanFiles(planFiles, profile)
 	if err != nil {
 		return err
 	}
 
 	if printPlan {
-		out, _ := yaml.Marshal(planMerged)
+		if explain {
+			out, err := types.ExplainPlanFiles(planFiles, profile)
+			if err != nil {
+				return err
+			}
+			fmt.Println(out)
+			os.Exit(0)
+		}
+
+		sensitive := []string{}
+		for _, planFile := range planFiles {
+			sensitive = append(sensitive, planFile.Sensitive...)
+		}
+
+		out, _ := yaml.Marshal(types.RedactedPlan(*planMerged, sensitive))
 		fmt.Println(string(out))
 		os.Exit(0)
 	}
@@ -124,6 +145,31 @@ func runApplyCommandE(command *cobra.Command, _ []string) error {
 		return fmt.Errorf("error while retreiving features: %s", err.Error())
 	}
 
+	if err := types.ValidateFeatures(plan); err != nil {
+		return err
+	}
+
+	registry, err := plan.RegistryPrefix()
+	if err != nil {
+		return err
+	}
+	plan.Registry = registry.String()
+
+	if plan.EnableECR {
+		extracted, err := prepareECRCredentials(&plan, "tmp")
+		// The extracted profiles hold plaintext keys, they are only
+		// needed until the secrets have been created
+		defer removeFiles(extracted)
+		if err != nil {
+			return errors.Wrap(err, "error with ECR credentials")
+		}
+	}
+
+	if plan.TLS && plan.TLSConfig.TLSMode() == types.CustomTLSMode {
+		secrets := append([]types.KeyValueNamespaceTuple{}, plan.Secrets...)
+		plan.Secrets = append(secrets, plan.CustomTLSSecrets()...)
+	}
+
 	clientArch, clientOS := env.GetClientArch()
 	userDir, err := config.InitUserDir()
 	if err != nil {
@@ -148,13 +194,16 @@ func runApplyCommandE(command *cobra.Command, _ []string) error {
 	log.Printf("Validating tools available in PATH: %q\n", newPath)
 
 	tools := []string{
-		"openssl version",
 		"kubectl version --client",
 		"helm version",
 		"faas-cli version",
 		"kubeseal --version",
 	}
 
+	if usesOpenSSL(enabledSecrets(plan)) {
+		tools = append(tools, "openssl version")
+	}
+
 	if err := validateTools(tools); err != nil {
 		return errors.Wrap(err, "validateTools")
 	}
@@ -167,24 +216,37 @@ func runApplyCommandE(command *cobra.Command, _ []string) error {
 		if err := validatePlan(plan); err != nil {
 			return errors.Wrap(err, "validatePlan")
 		}
+
+		if err := validateCustomTLS(plan, time.Now()); err != nil {
+			return errors.Wrap(err, "error with TLS certificate")
+		}
 	}
 
+	if err := validateExistingSecrets(enabledSecrets(plan)); err != nil {
+		return errors.Wrap(err, "validateExistingSecrets")
+	}
+
+	// The labels are computed once from the plan given to process, so
+	// that the applied plan and every object share the same plan-hash
+	labels := types.StandardLabels(plan)
+
 	if err = createNamespaces(); err != nil {
 		return errors.Wrap(err, "createNamespaces")
 	}
+	labelObjects("namespace", "", []string{"openfaas", "openfaas-fn", "cert-manager"}, labels)
 
-	fmt.Printf("Plan loaded from: %s\n", files)
+	printPlanSources(planFiles)
 
 	os.MkdirAll("tmp", 0700)
 	ioutil.WriteFile("tmp/go.mod", []byte("\n"), 0700)
 
 	fmt.Println("Validating registry credentials file")
-	if err := validateRegistryAuth(plan.Registry, plan.Secrets, plan.EnableECR); err != nil {
+	if err := validateRegistryAuth(plan, checkRegistry); err != nil {
 		return errors.Wrap(err, "error with registry credentials file")
 	}
 
 	start := time.Now()
-	err = process(plan, prefs)
+	err = process(plan, prefs, labels)
 	done := time.Since(start)
 
 	if err != nil {
@@ -192,9 +254,23 @@ func runApplyCommandE(command *cobra.Command, _ []string) error {
 	}
 
 	fmt.Printf("Plan completed in %fs.\n", done.Seconds())
+	printPlanSources(planFiles)
+
+	if err := saveAppliedPlan(*planMerged, planFiles, labels); err != nil {
+		log.Printf("Unable to save the plan to the cluster: %s\n", err.Error())
+	}
 	return nil
 }
 
+// printPlanSources lists each plan file with its checksum, and commit if
+// read from git, so that the same plan can be resolved again
+func printPlanSources(planFiles []types.PlanFile) {
+	fmt.Println("Plan loaded from:")
+	for _, planFile := range planFiles {
+		fmt.Printf("  - %s\n", planFile.Source)
+	}
+}
+
 // Vars are variables parsed from flags
 type Vars struct {
 	YamlFile string
@@ -235,39 +311,300 @@ func taskGivesStdout(tool string) error {
 	return nil
 }
 
-func validateRegistryAuth(regEndpoint string, planSecrets []types.KeyValueNamespaceTuple, enableECR bool) error {
-	if enableECR {
-		return nil
+// validateRegistryAuth checks that the registry-secret has credentials
+// for the registry, either in auths or through a helper such as ecr-login.
+// With ECR, the registry must match the account and region of ecr_config
+// and of the credHelpers.
+func validateRegistryAuth(plan types.Plan, checkOnline bool) error {
+	registry, err := plan.RegistryPrefix()
+	if err != nil {
+		return err
 	}
-	for _, planSecret := range planSecrets {
+
+	for _, planSecret := range plan.Secrets {
 		if planSecret.Name == "registry-secret" {
-			confFileLocation := planSecret.Files[0].ExpandValueFrom()
-			fileBytes, err := ioutil.ReadFile(confFileLocation)
+			var fileBytes []byte
+			var err error
+			if planSecret.Existing {
+				fileBytes, err = readExistingSecretKey(planSecret, "config.json")
+			} else {
+				fileBytes, err = ioutil.ReadFile(planSecret.Files[0].ExpandValueFrom())
+			}
 			if err != nil {
 				return err
 			}
-			return validators.ValidateRegistryAuth(regEndpoint, fileBytes)
+
+			if plan.EnableECR {
+				err := validators.ValidateECRConfig(registry, plan.ECRConfig.ECRRegion, fileBytes)
+				printECRPolicy(registry)
+				if err != nil {
+					return err
+				}
+			}
+
+			registryAuth, err := validators.FindRegistryAuth(registry, fileBytes)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Registry credentials for %s: %s\n", registryAuth.Host, registryAuth.Source())
+
+			if checkOnline && len(registryAuth.Helper) > 0 {
+				fmt.Printf("Skipping the push access check, credentials are held by docker-credential-%s\n", registryAuth.Helper)
+				return nil
+			}
+			if checkOnline {
+				return checkRegistryPush(registry, fileBytes)
+			}
+			return nil
 		}
 	}
 	return nil
 }
 
+// prepareECRCredentials validates the AWS credentials files of the ECR
+// secrets. When ecr_config.profile is set, only that profile is written
+// to a file under dir, as the default profile, and used for the secret.
+// The paths of the files written are returned, for removal after apply.
+func prepareECRCredentials(plan *types.Plan, dir string) ([]string, error) {
+	written := []string{}
+	profile := plan.ECRConfig.Profile
+	if len(profile) == 0 {
+		profile = "default"
+	}
+
+	// The secrets and their files are shared with the merged plan, which
+	// is saved to the cluster with the original paths
+	plan.Secrets = append([]types.KeyValueNamespaceTuple{}, plan.Secrets...)
+
+	feature, _ := types.GetFeature(types.ECRFeature)
+	for _, required := range feature.Secrets {
+		for i, secret := range plan.Secrets {
+			if secret.Name != required.Name || secret.Existing {
+				continue
+			}
+
+			for j, file := range secret.Files {
+				data, err := ioutil.ReadFile(file.ExpandValueFrom())
+				if err != nil {
+					return written, err
+				}
+
+				if len(plan.ECRConfig.Profile) == 0 {
+					if err := validators.ValidateAWSCredentials(data, profile); err != nil {
+						return written, fmt.Errorf("secret %s, file %s: %s", secret.Name, file.ValueFrom, err.Error())
+					}
+					continue
+				}
+
+				extracted, err := validators.ExtractAWSProfile(data, profile)
+				if err != nil {
+					return written, fmt.Errorf("secret %s, file %s: %s", secret.Name, file.ValueFrom, err.Error())
+				}
+
+				if err := os.MkdirAll(dir, 0700); err != nil {
+					return written, err
+				}
+				extractedPath := path.Join(dir, secret.Name+"-"+file.Name)
+				if err := ioutil.WriteFile(extractedPath, extracted, 0600); err != nil {
+					return written, err
+				}
+				written = append(written, extractedPath)
+
+				fmt.Printf("Using profile [%s] from %s for secret %s\n", profile, file.ValueFrom, secret.Name)
+				files := append([]types.FileSecret{}, plan.Secrets[i].Files...)
+				files[j].ValueFrom = extractedPath
+				plan.Secrets[i].Files = files
+			}
+		}
+	}
+	return written, nil
+}
+
+// removeFiles deletes files which hold credentials, errors are logged
+func removeFiles(files []string) {
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			log.Printf("unable to remove %s: %s\n", file, err.Error())
+		}
+	}
+}
+
+// checkRegistryPush logs into the registry with the credentials from the
+// registry-secret and checks that they can push under the registry prefix
+func checkRegistryPush(registry types.RegistryPrefix, fileBytes []byte) error {
+	username, password, err := validators.RegistryCredentials(registry, fileBytes)
+	if err != nil {
+		return err
+	}
+
+	baseURL, namespace := validators.RegistryEndpoint(registry)
+	fmt.Printf("Checking push access to %s as %s\n", registry.String(), username)
+
+	return validators.NewRegistryChecker().CheckPush(baseURL, namespace, username, password)
+}
+
+// validateCustomTLS checks that a custom certificate covers the wildcard
+// and, when OAuth is enabled, the auth host of the root domain
+func validateCustomTLS(plan types.Plan, now time.Time) error {
+	if !plan.TLS || plan.TLSConfig.TLSMode() != types.CustomTLSMode {
+		return nil
+	}
+
+	names := []string{"*." + plan.RootDomain}
+	if plan.EnableOAuth {
+		names = append(names, "auth.system."+plan.RootDomain)
+	}
+
+	expand := func(value string) string {
+		return types.FileSecret{ValueFrom: value}.ExpandValueFrom()
+	}
+
+	return validators.ValidateCertificate(expand(plan.TLSConfig.CertFile), expand(plan.TLSConfig.KeyFile), expand(plan.TLSConfig.CAFile), names, now)
+}
+
+// validateExistingSecrets checks that each secret marked as existing
+// is present in the cluster with all of the keys named in the plan
+func validateExistingSecrets(secrets []types.KeyValueNamespaceTuple) error {
+	for _, secret := range secrets {
+		if !secret.Existing {
+			continue
+		}
+
+		live, err := getClusterSecret(secret.Name, secret.Namespace)
+		if err != nil {
+			return err
+		}
+		if live == nil {
+			return fmt.Errorf("existing secret %s/%s was not found in the cluster", secret.Namespace, secret.Name)
+		}
+
+		if len(secret.Type) > 0 && secret.Type != live.Type {
+			return fmt.Errorf("existing secret %s/%s has type %s, but %s is required", secret.Namespace, secret.Name, live.Type, secret.Type)
+		}
+
+		for _, key := range secret.Keys() {
+			if _, ok := live.Data[key]; !ok {
+				return fmt.Errorf("existing secret %s/%s is missing the key: %s", secret.Namespace, secret.Name, key)
+			}
+		}
+		fmt.Printf("Found existing secret: %s/%s\n", secret.Namespace, secret.Name)
+	}
+	return nil
+}
+
+// readExistingSecretKey reads and decodes the value of a key of an existing
+// secret. The first key named in the plan is used, or defaultKey otherwise.
+func readExistingSecretKey(secret types.KeyValueNamespaceTuple, defaultKey string) ([]byte, error) {
+	key := defaultKey
+	if keys := secret.Keys(); len(keys) > 0 {
+		key = keys[0]
+	}
+
+	live, err := getClusterSecret(secret.Name, secret.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if live == nil {
+		return nil, fmt.Errorf("existing secret %s/%s was not found in the cluster", secret.Namespace, secret.Name)
+	}
+
+	encoded, ok := live.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("existing secret %s/%s is missing the key: %s", secret.Namespace, secret.Name, key)
+	}
+	return b64.StdEncoding.DecodeString(encoded)
+}
+
 func validatePlan(plan types.Plan) error {
+	secrets := enabledSecrets(plan)
+	for _, secret := range secrets {
+		if secret.Existing {
+			continue
+		}
+
+		err := filesExists(secret.Files)
+		if err != nil {
+			return err
+		}
+
+		if err := validateFileFormats(secret); err != nil {
+			return err
+		}
+
+		if err := validateLiterals(secret); err != nil {
+			return err
+		}
+	}
+
+	return types.ValidateKeyGenerators(secrets)
+}
+
+// enabledSecrets returns the secrets of the plan which match
+// at least one of its features
+func enabledSecrets(plan types.Plan) []types.KeyValueNamespaceTuple {
+	secrets := []types.KeyValueNamespaceTuple{}
 	for _, secret := range plan.Secrets {
 		if featureEnabled(plan.Features, secret.Filters) {
-			err := filesExists(secret.Files)
-			if err != nil {
-				return err
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
+// usesOpenSSL is true when a value_command relies on the openssl binary
+func usesOpenSSL(secrets []types.KeyValueNamespaceTuple) bool {
+	for _, secret := range secrets {
+		for _, file := range secret.Files {
+			if strings.HasPrefix(file.ValueCommand, "openssl ") {
+				return true
 			}
 		}
 	}
+	return false
+}
+
+// validateFileFormats checks the content of each file with a format,
+// files which are yet to be generated are skipped
+func validateFileFormats(secret types.KeyValueNamespaceTuple) error {
+	for _, file := range secret.Files {
+		if len(file.Format) == 0 {
+			continue
+		}
+
+		if !validators.IsFormat(file.Format) {
+			return fmt.Errorf("secret %s, file %s: unsupported format: %q, use one of: %s",
+				secret.Name, file.Name, file.Format, strings.Join(validators.Formats, ", "))
+		}
+
+		data, err := ioutil.ReadFile(file.ExpandValueFrom())
+		if err != nil {
+			if os.IsNotExist(err) && (len(file.ValueCommand) > 0 || len(file.Generator) > 0) {
+				continue
+			}
+			return err
+		}
+
+		if err := validators.ValidateFormat(file.Format, data); err != nil {
+			return fmt.Errorf("secret %s, file %s (%s) is not a valid %s: %s",
+				secret.Name, file.Name, file.ExpandValueFrom(), file.Format, err.Error())
+		}
+	}
+	return nil
+}
+
+func validateLiterals(secret types.KeyValueNamespaceTuple) error {
+	for _, literal := range secret.Literals {
+		if err := literal.Generate.Validate(); err != nil {
+			return fmt.Errorf("secret %s, literal %s has an invalid generate policy: %s", secret.Name, literal.Name, err.Error())
+		}
+	}
 	return nil
 }
 
 func filesExists(files []types.FileSecret) error {
 	if len(files) > 0 {
 		for _, file := range files {
-			if len(file.ValueCommand) == 0 {
+			if len(file.ValueCommand) == 0 && len(file.Generator) == 0 {
 				if _, err := os.Stat(file.ExpandValueFrom()); err != nil {
 					return err
 				}
@@ -277,7 +614,7 @@ func filesExists(files []types.FileSecret) error {
 	return nil
 }
 
-func process(plan types.Plan, prefs InstallPreferences) error {
+func process(plan types.Plan, prefs InstallPreferences, labels map[string]string) error {
 
 	if plan.OpenFaaSCloudVersion == "" {
 		plan.OpenFaaSCloudVersion = "master"
@@ -289,7 +626,9 @@ func process(plan types.Plan, prefs InstallPreferences) error {
 	}
 
 	if !prefs.SkipCreateSecrets {
-		createSecrets(plan)
+		if err := createSecrets(plan, labels); err != nil {
+			return errors.Wrap(err, "createSecrets")
+		}
 	}
 
 	saErr := patchFnServiceaccount()
@@ -311,7 +650,7 @@ func process(plan types.Plan, prefs InstallPreferences) error {
 		}
 	}
 
-	if plan.TLS {
+	if plan.UsesCertManager() {
 		if err := installCertmanager(); err != nil {
 			return errors.Wrap(err, "installCertmanager")
 		}
@@ -321,13 +660,14 @@ func process(plan types.Plan, prefs InstallPreferences) error {
 	if functionAuthErr != nil {
 		log.Println(functionAuthErr.Error())
 	}
+	labelObjects("secret", "openfaas-fn", []string{"basic-auth-user", "basic-auth-password"}, labels)
 
 	if err := installOpenfaas(plan.ScaleToZero, plan.IngressOperator, plan.OpenFaaSOperator); err != nil {
 		return errors.Wrap(err, "unable to install openfaas")
 	}
 
 	retries := 260
-	if plan.TLS {
+	if plan.UsesCertManager() {
 		for i := 0; i < retries; i++ {
 			log.Printf("Is cert-manager ready? %d/%d\n", i+1, retries)
 			ready := certManagerReady()
@@ -338,13 +678,13 @@ func process(plan types.Plan, prefs InstallPreferences) error {
 		}
 	}
 
-	ingressErr := ingress.Apply(plan)
+	ingressErr := ingress.Apply(plan, labels)
 	if ingressErr != nil {
 		log.Println(ingressErr)
 	}
 
-	if plan.TLS {
-		tlsErr := tls.Apply(plan)
+	if plan.UsesCertManager() {
+		tlsErr := tls.Apply(plan, labels)
 		if tlsErr != nil {
 			log.Println(tlsErr)
 		}
@@ -376,10 +716,24 @@ func process(plan types.Plan, prefs InstallPreferences) error {
 	if err := deployCloudComponents(plan); err != nil {
 		return errors.Wrap(err, "deployCloudComponents")
 	}
+	labelObjects("secret", "openfaas-fn", []string{"payload-secret", "sealedsecrets-public-key"}, labels)
 
 	return nil
 }
 
+// labelObjects adds the standard labels to objects which were created by
+// a script, or from a manifest which is not ours, failures are only logged
+func labelObjects(kind, namespace string, names []string, labels map[string]string) {
+	res, err := types.BuildLabelObjectsTask(kind, namespace, names, labels).Execute()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if res.ExitCode != 0 {
+		log.Printf("unable to label %s %s: %s\n", kind, strings.Join(names, ", "), res.Stderr)
+	}
+}
+
 func helmRepoAdd(name, repo string) error {
 	log.Printf("Adding %s helm repo\n", name)
 
@@ -691,29 +1045,65 @@ func installCertmanager() error {
 	return nil
 }
 
-func createSecrets(plan types.Plan) error {
-	for _, secret := range plan.Secrets {
-		if featureEnabled(plan.Features, secret.Filters) {
-			fmt.Printf("Creating secret: %s\n", secret.Name)
+func createSecrets(plan types.Plan, labels map[string]string) error {
+	secrets := enabledSecrets(plan)
+	if err := types.GenerateKeyFiles(secrets); err != nil {
+		return err
+	}
+
+	for _, secret := range secrets {
+		if secret.Existing {
+			fmt.Printf("Using existing secret: %s\n", secret.Name)
+			continue
+		}
+
+		fmt.Printf("Creating secret: %s\n", secret.Name)
 
-			command := types.BuildSecretTask(secret)
-			fmt.Printf("Secret - %s %s\n", command.Command, strings.Join(command.Args, " "))
-			res, err := command.Execute()
+		command := types.BuildSecretTask(secret)
+		fmt.Printf("Secret - %s %s\n", command.Command, strings.Join(redactLiteralArgs(command.Args), " "))
+		res, err := command.Execute()
+		if err != nil {
+			log.Println(err)
+		}
+
+		out := res.Stdout
+		if len(res.Stderr) > 0 {
+			out = out + " / " + res.Stderr
+		}
+		fmt.Printf("%s\n", out)
+
+		for _, metadataTask := range types.BuildSecretMetadataTasks(secret, labels) {
+			res, err := metadataTask.Execute()
 			if err != nil {
 				log.Println(err)
+				continue
 			}
-
-			out := res.Stdout
-			if len(res.Stderr) > 0 {
-				out = out + " / " + res.Stderr
+			if res.ExitCode != 0 {
+				log.Printf("unable to %s secret %s: %s\n", metadataTask.Args[0], secret.Name, res.Stderr)
 			}
-			fmt.Printf("%s\n", out)
 		}
 	}
 
 	return nil
 }
 
+// redactLiteralArgs masks the values given to kubectl via --from-literal
+// so that they are not written to the logs
+func redactLiteralArgs(args []string) []string {
+	redacted := []string{}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--from-literal=") {
+			if i := strings.Index(arg, "="); i > -1 {
+				if j := strings.Index(arg[i+1:], "="); j > -1 {
+					arg = arg[:i+1+j+1] + types.RedactedValue
+				}
+			}
+		}
+		redacted = append(redacted, arg)
+	}
+	return redacted
+}
+
 func sealedSecretsReady() bool {
 
 	task := execute.ExecTask{
@@ -856,28 +1246,38 @@ func filterFeatures(plan types.Plan) (types.Plan, error) {
 }
 
 func filterDNSFeature(plan types.Plan) (types.Plan, error) {
-	if plan.TLSConfig.DNSService == types.DigitalOcean {
-		plan.Features = append(plan.Features, types.DODNS)
-	} else if plan.TLSConfig.DNSService == types.CloudDNS {
-		plan.Features = append(plan.Features, types.GCPDNS)
-	} else if plan.TLSConfig.DNSService == types.Route53 {
-		plan.Features = append(plan.Features, types.Route53DNS)
-	} else if plan.TLSConfig.DNSService == types.Cloudflare {
-		plan.Features = append(plan.Features, types.CloudflareDNS)
-	} else {
+	switch plan.TLSConfig.TLSMode() {
+	case types.CustomTLSMode:
+		plan.Features = append(plan.Features, types.CustomTLSFeature)
+		return plan, nil
+	case types.ACMETLSMode:
+	default:
+		return plan, fmt.Errorf("Error unsupported TLS mode: %s, use %s or %s", plan.TLSConfig.Mode, types.ACMETLSMode, types.CustomTLSMode)
+	}
+
+	switch plan.TLSConfig.ChallengeType() {
+	case types.HTTP01Challenge:
+		plan.Features = append(plan.Features, types.HTTP01Feature)
+		return plan, nil
+	case types.DNS01Challenge:
+	default:
+		return plan, fmt.Errorf("Error unsupported TLS challenge: %s, use %s or %s", plan.TLSConfig.Challenge, types.DNS01Challenge, types.HTTP01Challenge)
+	}
+
+	feature, ok := types.FeatureFor("tls_config.dns_service", plan.TLSConfig.DNSService)
+	if !ok {
 		return plan, fmt.Errorf("Error unavailable DNS service provider: %s", plan.TLSConfig.DNSService)
 	}
+	plan.Features = append(plan.Features, feature.Name)
 	return plan, nil
 }
 
 func filterGitRepositoryManager(plan types.Plan) (types.Plan, error) {
-	if plan.SCM == types.GitLabSCM {
-		plan.Features = append(plan.Features, types.GitLabFeature)
-	} else if plan.SCM == types.GitHubSCM {
-		plan.Features = append(plan.Features, types.GitHubFeature)
-	} else {
+	feature, ok := types.FeatureFor("scm", plan.SCM)
+	if !ok {
 		return plan, fmt.Errorf("Error unsupported Git repository manager: %s", plan.SCM)
 	}
+	plan.Features = append(plan.Features, feature.Name)
 	return plan, nil
 }
 
@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -39,12 +42,60 @@ func Test_filterDNSFeature(t *testing.T) {
 			expectedFeature: types.CloudflareDNS,
 			expectedErr:     nil,
 		},
+		{
+			title:           "DNS Service provider is Azure",
+			plan:            types.Plan{TLSConfig: types.TLSConfig{DNSService: types.Azure}},
+			expectedFeature: types.AzureDNS,
+			expectedErr:     nil,
+		},
+		{
+			title:           "DNS Service provider is RFC2136",
+			plan:            types.Plan{TLSConfig: types.TLSConfig{DNSService: types.RFC2136}},
+			expectedFeature: types.RFC2136DNS,
+			expectedErr:     nil,
+		},
+		{
+			title:           "DNS Service provider is acme-dns",
+			plan:            types.Plan{TLSConfig: types.TLSConfig{DNSService: types.ACMEDNSProvider}},
+			expectedFeature: types.ACMEDNS,
+			expectedErr:     nil,
+		},
+		{
+			title:           "DNS Service provider is Akamai",
+			plan:            types.Plan{TLSConfig: types.TLSConfig{DNSService: types.Akamai}},
+			expectedFeature: types.AkamaiDNS,
+			expectedErr:     nil,
+		},
 		{
 			title:           "DNS Service provider is not supported",
 			plan:            types.Plan{TLSConfig: types.TLSConfig{DNSService: "unsupporteddns"}},
 			expectedFeature: "",
 			expectedErr:     errors.New("Error unavailable DNS service provider"),
 		},
+		{
+			title:           "HTTP01 challenge needs no DNS Service provider",
+			plan:            types.Plan{TLSConfig: types.TLSConfig{Challenge: types.HTTP01Challenge, DNSService: types.DigitalOcean}},
+			expectedFeature: types.HTTP01Feature,
+			expectedErr:     nil,
+		},
+		{
+			title:           "Custom TLS mode needs no DNS Service provider",
+			plan:            types.Plan{TLSConfig: types.TLSConfig{Mode: types.CustomTLSMode, DNSService: types.DigitalOcean}},
+			expectedFeature: types.CustomTLSFeature,
+			expectedErr:     nil,
+		},
+		{
+			title:           "TLS mode is not supported",
+			plan:            types.Plan{TLSConfig: types.TLSConfig{Mode: "vault"}},
+			expectedFeature: "",
+			expectedErr:     errors.New("Error unsupported TLS mode: vault"),
+		},
+		{
+			title:           "TLS challenge is not supported",
+			plan:            types.Plan{TLSConfig: types.TLSConfig{Challenge: "tls-alpn01"}},
+			expectedFeature: "",
+			expectedErr:     errors.New("Error unsupported TLS challenge: tls-alpn01"),
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.title, func(t *testing.T) {
@@ -229,3 +280,96 @@ func Test_filterGitRepositoryManager(t *testing.T) {
 		})
 	}
 }
+
+func Test_redactLiteralArgs(t *testing.T) {
+	args := []string{"create", "secret", "generic", "--from-literal=basic-auth-password=pa=ss", "--from-file=key=./tmp/key"}
+
+	got := strings.Join(redactLiteralArgs(args), " ")
+	want := "create secret generic --from-literal=basic-auth-password=<redacted> --from-file=key=./tmp/key"
+	if got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+}
+
+func Test_prepareECRCredentials_Profile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ecr-credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	credentials := filepath.Join(dir, "credentials")
+	ioutil.WriteFile(credentials, []byte(`[default]
+aws_access_key_id = ADMINKEY
+aws_secret_access_key = ADMINSECRET
+
+[ofc-ecr]
+aws_access_key_id = ECRKEY
+aws_secret_access_key = ECRSECRET
+`), 0600)
+
+	secret := types.KeyValueNamespaceTuple{
+		Name:    "aws-ecr-credentials",
+		Files:   []types.FileSecret{{Name: "credentials", ValueFrom: credentials}},
+		Filters: []string{types.ECRFeature},
+	}
+	plan := types.Plan{
+		EnableECR: true,
+		ECRConfig: types.ECRConfig{ECRRegion: "eu-west-1", Profile: "ofc-ecr"},
+		Secrets:   []types.KeyValueNamespaceTuple{secret},
+	}
+	original := plan.Secrets
+
+	written, err := prepareECRCredentials(&plan, filepath.Join(dir, "tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extracted := plan.Secrets[0].Files[0].ValueFrom
+	if extracted == credentials {
+		t.Fatalf("want the secret to use the extracted profile")
+	}
+	if original[0].Files[0].ValueFrom != credentials {
+		t.Errorf("want the original plan to keep its path, got: %s", original[0].Files[0].ValueFrom)
+	}
+
+	data, _ := ioutil.ReadFile(extracted)
+	if strings.Contains(string(data), "ADMIN") || !strings.Contains(string(data), "ECRKEY") {
+		t.Errorf("want only the ofc-ecr profile, got:\n%s", string(data))
+	}
+	if info, _ := os.Stat(extracted); info.Mode().Perm() != 0600 {
+		t.Errorf("want mode 0600, got: %o", info.Mode().Perm())
+	}
+
+	if len(written) != 1 || written[0] != extracted {
+		t.Fatalf("want the extracted file to be returned for removal, got: %v", written)
+	}
+	removeFiles(written)
+	if _, err := os.Stat(extracted); !os.IsNotExist(err) {
+		t.Errorf("want the extracted profile to be removed, got: %v", err)
+	}
+}
+
+func Test_prepareECRCredentials_NoDefaultProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ecr-credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	credentials := filepath.Join(dir, "credentials")
+	ioutil.WriteFile(credentials, []byte("[ofc-ecr]\naws_access_key_id = ECRKEY\naws_secret_access_key = ECRSECRET\n"), 0600)
+
+	plan := types.Plan{
+		EnableECR: true,
+		Secrets: []types.KeyValueNamespaceTuple{{
+			Name:  "aws-ecr-createrepo-credentials",
+			Files: []types.FileSecret{{Name: "credentials", ValueFrom: credentials}},
+		}},
+	}
+
+	_, err = prepareECRCredentials(&plan, filepath.Join(dir, "tmp"))
+	if err == nil || !strings.Contains(err.Error(), "profile [default] not found") {
+		t.Errorf("want missing default profile error, got: %v", err)
+	}
+}
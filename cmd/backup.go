@@ -0,0 +1,327 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/alexellis/arkade/pkg/k8s"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// sealedSecretsKeyLabel marks the private keys of the sealed-secrets controller
+	sealedSecretsKeyLabel = "sealedsecrets.bitnami.com/sealed-secrets-key"
+
+	// backupPassphraseEnv is read for the passphrase when no other option is given
+	backupPassphraseEnv = "OFC_BACKUP_PASSPHRASE"
+)
+
+func init() {
+	rootCommand.AddCommand(backupCmd)
+	rootCommand.AddCommand(restoreCmd)
+
+	backupCmd.Flags().StringArrayP("file", "f", []string{""}, "A number of init.yaml plan files")
+	backupCmd.Flags().String("profile", "", "Merge the named overlay from the profiles of the plan")
+	backupCmd.Flags().StringP("output", "o", "ofc-backup.age", "The encrypted archive to write")
+	backupCmd.Flags().StringArray("recipient", []string{}, "An age public key to encrypt to, instead of a passphrase")
+	backupCmd.Flags().Bool("passphrase-stdin", false, "Read the passphrase from stdin, otherwise "+backupPassphraseEnv+" is used")
+
+	restoreCmd.Flags().StringP("input", "i", "ofc-backup.age", "The encrypted archive to restore")
+	restoreCmd.Flags().String("identity", "", "An age identity file to decrypt with, instead of a passphrase")
+	restoreCmd.Flags().Bool("passphrase-stdin", false, "Read the passphrase from stdin, otherwise "+backupPassphraseEnv+" is used")
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up the secrets of a plan and the sealed-secrets key",
+	Long: `Collect every secret managed by the plan, along with the private key of
+the sealed-secrets controller, into an archive encrypted with age.`,
+	Example: `  OFC_BACKUP_PASSPHRASE=... ofc-bootstrap backup -f init.yaml -o ofc-backup.age
+
+  ofc-bootstrap backup -f init.yaml --recipient age1...`,
+	RunE:         runBackupE,
+	SilenceUsage: true,
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore secrets from an encrypted backup",
+	Long: `Re-create the secrets from an archive written by "backup". Run this on a
+fresh cluster before "apply", existing secrets are then kept by "apply" and
+the sealed-secrets controller starts with the restored key.`,
+	Example:      `  OFC_BACKUP_PASSPHRASE=... ofc-bootstrap restore -i ofc-backup.age`,
+	RunE:         runRestoreE,
+	SilenceUsage: true,
+}
+
+func runBackupE(command *cobra.Command, _ []string) error {
+	files, err := command.Flags().GetStringArray("file")
+	if err != nil {
+		return err
+	}
+	output, _ := command.Flags().GetString("output")
+	recipientKeys, _ := command.Flags().GetStringArray("recipient")
+	passStdIn, _ := command.Flags().GetBool("passphrase-stdin")
+
+	recipients, err := backupRecipients(recipientKeys, passStdIn)
+	if err != nil {
+		return err
+	}
+
+	profile, err := command.Flags().GetString("profile")
+	if err != nil {
+		return err
+	}
+
+	planMerged, err := loadPlans(files, profile)
+	if err != nil {
+		return err
+	}
+
+	plan, err := filterFeatures(*planMerged)
+	if err != nil {
+		return fmt.Errorf("error while retreiving features: %s", err.Error())
+	}
+
+	items := []map[string]interface{}{}
+	for _, secret := range enabledSecrets(plan) {
+		res, err := k8s.KubectlTask("get", "secret", secret.Name, "-n", secret.Namespace, "-o", "json", "--ignore-not-found")
+		if err != nil {
+			return err
+		}
+		if res.ExitCode != 0 {
+			return fmt.Errorf("error getting secret %s/%s: %s", secret.Namespace, secret.Name, res.Stderr)
+		}
+		if len(strings.TrimSpace(res.Stdout)) == 0 {
+			fmt.Printf("Skipping %s/%s, not found in cluster\n", secret.Namespace, secret.Name)
+			continue
+		}
+
+		item := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(res.Stdout), &item); err != nil {
+			return err
+		}
+		items = append(items, item)
+		fmt.Printf("Added secret %s/%s\n", secret.Namespace, secret.Name)
+	}
+
+	res, err := k8s.KubectlTask("get", "secret", "-n", "kube-system", "-l", sealedSecretsKeyLabel, "-o", "json")
+	if err != nil {
+		return err
+	}
+	if res.ExitCode != 0 {
+		return fmt.Errorf("error getting sealed-secrets keys: %s", res.Stderr)
+	}
+
+	keys := struct {
+		Items []map[string]interface{} `json:"items"`
+	}{}
+	if err := json.Unmarshal([]byte(res.Stdout), &keys); err != nil {
+		return err
+	}
+	if len(keys.Items) == 0 {
+		fmt.Println("Warning: no sealed-secrets keys were found in kube-system")
+	}
+	items = append(items, keys.Items...)
+	fmt.Printf("Added %d sealed-secrets key(s)\n", len(keys.Items))
+
+	archive, err := buildSecretArchive(items)
+	if err != nil {
+		return err
+	}
+
+	if err := writeEncryptedArchive(output, archive, recipients); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %d secret(s) to %s\n", len(items), output)
+	return nil
+}
+
+func runRestoreE(command *cobra.Command, _ []string) error {
+	input, _ := command.Flags().GetString("input")
+	identityFile, _ := command.Flags().GetString("identity")
+	passStdIn, _ := command.Flags().GetBool("passphrase-stdin")
+
+	identities, err := backupIdentities(identityFile, passStdIn)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	archive, err := decryptArchive(file, identities)
+	if err != nil {
+		return fmt.Errorf("unable to decrypt %s: %s", input, err.Error())
+	}
+
+	if err := createNamespaces(); err != nil {
+		return err
+	}
+
+	res, err := k8s.KubectlTaskStdin(bytes.NewReader(archive), "apply", "-f", "-")
+	if err != nil {
+		return err
+	}
+	if res.ExitCode != 0 {
+		return fmt.Errorf("error restoring secrets: %s %s", res.Stdout, res.Stderr)
+	}
+
+	fmt.Print(res.Stdout)
+	fmt.Printf("Restored secrets from %s, you can now run ofc-bootstrap apply\n", input)
+	return nil
+}
+
+// buildSecretArchive writes the secrets into a v1 List, without the
+// metadata which is assigned by the cluster that they came from
+func buildSecretArchive(items []map[string]interface{}) ([]byte, error) {
+	for _, item := range items {
+		metadata, ok := item["metadata"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, field := range []string{"uid", "resourceVersion", "creationTimestamp", "selfLink", "managedFields", "ownerReferences"} {
+			delete(metadata, field)
+		}
+
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+		}
+	}
+
+	list := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      items,
+	}
+
+	return json.MarshalIndent(list, "", "  ")
+}
+
+func encryptArchive(w io.Writer, archive []byte, recipients []age.Recipient) error {
+	writer, err := age.Encrypt(w, recipients...)
+	if err != nil {
+		return err
+	}
+
+	if _, err := writer.Write(archive); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// writeEncryptedArchive encrypts to a temporary file next to output, which
+// is renamed only on success so that a failure never leaves a truncated file
+func writeEncryptedArchive(output string, archive []byte, recipients []age.Recipient) error {
+	file, err := ioutil.TempFile(filepath.Dir(output), "."+filepath.Base(output)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := file.Name()
+
+	if err := encryptArchive(file, archive, recipients); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, output); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+func decryptArchive(r io.Reader, identities []age.Identity) ([]byte, error) {
+	reader, err := age.Decrypt(r, identities...)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(reader)
+}
+
+func backupRecipients(recipientKeys []string, passStdIn bool) ([]age.Recipient, error) {
+	if len(recipientKeys) > 0 {
+		recipients := []age.Recipient{}
+		for _, key := range recipientKeys {
+			recipient, err := age.ParseX25519Recipient(key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --recipient %q: %s", key, err.Error())
+			}
+			recipients = append(recipients, recipient)
+		}
+		return recipients, nil
+	}
+
+	passphrase, err := readPassphrase(passStdIn)
+	if err != nil {
+		return nil, err
+	}
+
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return []age.Recipient{recipient}, nil
+}
+
+func backupIdentities(identityFile string, passStdIn bool) ([]age.Identity, error) {
+	if len(identityFile) > 0 {
+		file, err := os.Open(identityFile)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		return age.ParseIdentities(file)
+	}
+
+	passphrase, err := readPassphrase(passStdIn)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return []age.Identity{identity}, nil
+}
+
+func readPassphrase(passStdIn bool) (string, error) {
+	passphrase := os.Getenv(backupPassphraseEnv)
+
+	if passStdIn {
+		fmt.Printf("Enter your passphrase, hit enter then type Ctrl+D\n\nPassphrase: ")
+		passphraseStdin, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return "", err
+		}
+		passphrase = strings.TrimSpace(string(passphraseStdin))
+	}
+
+	if len(passphrase) == 0 {
+		return "", fmt.Errorf("give a passphrase with --passphrase-stdin or %s, or use an age key", backupPassphraseEnv)
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,128 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func Test_buildSecretArchive_RemovesClusterMetadata(t *testing.T) {
+	items := []map[string]interface{}{
+		{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata": map[string]interface{}{
+				"name":              "basic-auth",
+				"namespace":         "openfaas",
+				"uid":               "1234",
+				"resourceVersion":   "99",
+				"creationTimestamp": "2020-01-01T00:00:00Z",
+				"labels":            map[string]interface{}{sealedSecretsKeyLabel: "active"},
+			},
+			"data": map[string]interface{}{"basic-auth-user": "YWRtaW4="},
+		},
+	}
+
+	archive, err := buildSecretArchive(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := struct {
+		Kind  string                   `json:"kind"`
+		Items []map[string]interface{} `json:"items"`
+	}{}
+	if err := json.Unmarshal(archive, &list); err != nil {
+		t.Fatal(err)
+	}
+
+	if list.Kind != "List" || len(list.Items) != 1 {
+		t.Fatalf("want a List with 1 item, got: %s with %d", list.Kind, len(list.Items))
+	}
+
+	metadata := list.Items[0]["metadata"].(map[string]interface{})
+	for _, field := range []string{"uid", "resourceVersion", "creationTimestamp"} {
+		if _, ok := metadata[field]; ok {
+			t.Errorf("want %s to be removed", field)
+		}
+	}
+	if _, ok := metadata["labels"]; !ok {
+		t.Errorf("want labels to be kept for the sealed-secrets key")
+	}
+}
+
+func Test_encryptArchive_RoundTrip(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte(`{"kind": "List"}`)
+	buf := bytes.Buffer{}
+	if err := encryptArchive(&buf, want, []age.Recipient{identity.Recipient()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(buf.Bytes(), want) {
+		t.Errorf("want archive to be encrypted")
+	}
+
+	got, err := decryptArchive(&buf, []age.Identity{identity})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+}
+
+func Test_writeEncryptedArchive_KeepsOutputOnFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "ofc-backup.age")
+	ioutil.WriteFile(output, []byte("previous backup"), 0600)
+
+	if err := writeEncryptedArchive(output, []byte(`{"kind": "List"}`), []age.Recipient{}); err == nil {
+		t.Fatalf("want an error without recipients")
+	}
+
+	got, _ := ioutil.ReadFile(output)
+	if string(got) != "previous backup" {
+		t.Errorf("want the previous backup kept, got: %q", got)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("want no temporary file left, got %d files", len(files))
+	}
+
+	identity, _ := age.GenerateX25519Identity()
+	if err := writeEncryptedArchive(output, []byte(`{"kind": "List"}`), []age.Recipient{identity.Recipient()}); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(output); info.Mode().Perm() != 0600 {
+		t.Errorf("want mode 0600, got: %o", info.Mode().Perm())
+	}
+}
+
+func Test_backupRecipients_NeedsPassphraseOrKey(t *testing.T) {
+	os.Setenv(backupPassphraseEnv, "")
+
+	if _, err := backupRecipients([]string{}, false); err == nil {
+		t.Errorf("want an error without a passphrase or recipient")
+	}
+
+	if _, err := backupRecipients([]string{"not-a-key"}, false); err == nil {
+		t.Errorf("want an error for an invalid recipient")
+	}
+}
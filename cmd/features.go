@@ -0,0 +1,60 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/openfaas/ofc-bootstrap/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCommand.AddCommand(featuresCmd)
+}
+
+var featuresCmd = &cobra.Command{
+	Use:   "features",
+	Short: "List the features of a plan and what each one requires",
+	Long: `List each feature, the plan setting which enables it, the secrets and
+keys it requires, the plan fields it requires and the features it conflicts
+with. These are checked by apply before anything is installed.`,
+	RunE:         runFeaturesE,
+	SilenceUsage: true,
+}
+
+func runFeaturesE(_ *cobra.Command, _ []string) error {
+	fmt.Print(formatFeatures(types.Features))
+	return nil
+}
+
+func formatFeatures(features []types.Feature) string {
+	buf := bytes.Buffer{}
+	for i, feature := range features {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+
+		secrets := []string{}
+		for _, secret := range feature.Secrets {
+			secrets = append(secrets, secret.String())
+		}
+
+		fmt.Fprintf(&buf, "%s - %s\n", feature.Name, feature.Description)
+		fmt.Fprintf(&buf, "  enabled by: %s\n", feature.EnabledBy)
+		fmt.Fprintf(&buf, "  secrets:    %s\n", listOrNone(secrets))
+		fmt.Fprintf(&buf, "  fields:     %s\n", listOrNone(feature.Fields))
+		fmt.Fprintf(&buf, "  conflicts:  %s\n", listOrNone(feature.Conflicts))
+	}
+	return buf.String()
+}
+
+func listOrNone(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ", ")
+}
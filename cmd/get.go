@@ -0,0 +1,204 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/alexellis/arkade/pkg/k8s"
+	execute "github.com/alexellis/go-execute/pkg/v1"
+	"github.com/openfaas/ofc-bootstrap/pkg/types"
+	"github.com/openfaas/ofc-bootstrap/version"
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func init() {
+	rootCommand.AddCommand(getCmd)
+	getCmd.AddCommand(getPlanCmd)
+
+	getPlanCmd.Flags().Bool("plan-only", false, "Print only the plan, without the version, timestamp, sources and components")
+}
+
+var getCmd = &cobra.Command{
+	Use:   "get",
+	Short: "Display resources recorded in the cluster",
+}
+
+var getPlanCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Print the last plan applied to the cluster",
+	Long: `Print the plan recorded by the last successful apply, with the version of
+ofc-bootstrap, the time it completed, the plan files it was read from and
+the versions of the components installed. Secret values are not stored.`,
+	Example:      `  ofc-bootstrap get plan --plan-only > last-applied.yaml`,
+	RunE:         runGetPlanE,
+	SilenceUsage: true,
+}
+
+func runGetPlanE(command *cobra.Command, _ []string) error {
+	planOnly, _ := command.Flags().GetBool("plan-only")
+
+	applied, err := readAppliedPlan()
+	if err != nil {
+		return err
+	}
+
+	var out []byte
+	if planOnly {
+		out, err = yaml.Marshal(applied.Plan)
+	} else {
+		out, err = yaml.Marshal(applied)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(string(out))
+	return nil
+}
+
+// readAppliedPlan reads the plan recorded by saveAppliedPlan
+func readAppliedPlan() (*types.AppliedPlan, error) {
+	res, err := k8s.KubectlTask("get", "configmap", "-n", types.AppliedPlanNamespace, types.AppliedPlanName,
+		"-o", "json", "--ignore-not-found")
+	if err != nil {
+		return nil, err
+	}
+	if res.ExitCode != 0 {
+		return nil, fmt.Errorf("error getting configmap %s/%s: %s", types.AppliedPlanNamespace, types.AppliedPlanName, res.Stderr)
+	}
+	if len(bytes.TrimSpace([]byte(res.Stdout))) == 0 {
+		return nil, fmt.Errorf("no plan has been applied to this cluster, configmap %s/%s was not found",
+			types.AppliedPlanNamespace, types.AppliedPlanName)
+	}
+
+	configMap := struct {
+		Data map[string]string `json:"data"`
+	}{}
+	if err := json.Unmarshal([]byte(res.Stdout), &configMap); err != nil {
+		return nil, err
+	}
+
+	applied := types.AppliedPlan{}
+	if err := yaml.Unmarshal([]byte(configMap.Data[types.AppliedPlanKey]), &applied); err != nil {
+		return nil, fmt.Errorf("unmarshal of configmap %s/%s gave error: %s", types.AppliedPlanNamespace, types.AppliedPlanName, err.Error())
+	}
+
+	return &applied, nil
+}
+
+// saveAppliedPlan records the merged plan, without secret values, in
+// a ConfigMap after a successful apply. labels are the same as those of
+// the objects created by apply.
+func saveAppliedPlan(plan types.Plan, planFiles []types.PlanFile, labels map[string]string) error {
+	components, err := installedComponents(plan)
+	if err != nil {
+		return err
+	}
+
+	applied := newAppliedPlan(plan, planFiles, components, labels)
+	manifest, err := types.BuildAppliedPlanConfigMap(applied, labels)
+	if err != nil {
+		return err
+	}
+
+	res, err := k8s.KubectlTaskStdin(bytes.NewReader(manifest), "apply", "-f", "-")
+	if err != nil {
+		return err
+	}
+	if res.ExitCode != 0 {
+		return fmt.Errorf("error saving the plan: %s %s", res.Stdout, res.Stderr)
+	}
+
+	fmt.Printf("Plan saved to configmap %s/%s\n", types.AppliedPlanNamespace, types.AppliedPlanName)
+	return nil
+}
+
+// helmRelease is the subset of "helm list -o json" used to
+// record the versions of the charts installed by arkade
+type helmRelease struct {
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+	Chart      string `json:"chart"`
+	AppVersion string `json:"app_version"`
+}
+
+// newAppliedPlan builds the record of an apply, its plan-hash is read
+// from labels rather than from plan
+func newAppliedPlan(plan types.Plan, planFiles []types.PlanFile, components []types.Component, labels map[string]string) types.AppliedPlan {
+	sensitive := []string{}
+	sources := []string{}
+	for _, planFile := range planFiles {
+		sensitive = append(sensitive, planFile.Sensitive...)
+		sources = append(sources, planFile.Source)
+	}
+
+	return types.AppliedPlan{
+		Version:    version.GetVersion(),
+		GitCommit:  version.GitCommit,
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		Sources:    sources,
+		Components: components,
+		PlanHash:   labels[types.PlanHashLabel],
+		Plan:       types.StripSecretValues(types.RedactedPlan(plan, sensitive)),
+	}
+}
+
+// installedComponents lists the helm releases in the cluster and the
+// version of the OpenFaaS Cloud functions
+func installedComponents(plan types.Plan) ([]types.Component, error) {
+	task := execute.ExecTask{
+		Command:     "helm",
+		Args:        []string{"list", "--all-namespaces", "-o", "json"},
+		StreamStdio: false,
+	}
+
+	res, err := task.Execute()
+	if err != nil {
+		return nil, err
+	}
+	if res.ExitCode != 0 {
+		return nil, fmt.Errorf("error listing helm releases: %s", res.Stderr)
+	}
+
+	components, err := parseHelmReleases([]byte(res.Stdout))
+	if err != nil {
+		return nil, err
+	}
+
+	cloudVersion := plan.OpenFaaSCloudVersion
+	if len(cloudVersion) == 0 {
+		cloudVersion = "master"
+	}
+
+	return append(components, types.Component{
+		Name:      "openfaas-cloud",
+		Namespace: "openfaas",
+		Version:   cloudVersion,
+	}), nil
+}
+
+func parseHelmReleases(data []byte) ([]types.Component, error) {
+	releases := []helmRelease{}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, &releases); err != nil {
+			return nil, fmt.Errorf("unable to parse helm releases: %s", err.Error())
+		}
+	}
+
+	components := []types.Component{}
+	for _, release := range releases {
+		components = append(components, types.Component{
+			Name:      release.Name,
+			Namespace: release.Namespace,
+			Chart:     release.Chart,
+			Version:   release.AppVersion,
+		})
+	}
+	return components, nil
+}
@@ -0,0 +1,125 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openfaas/ofc-bootstrap/pkg/types"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func Test_parseHelmReleases(t *testing.T) {
+	data := []byte(`[{"name":"openfaas","namespace":"openfaas","revision":"1","status":"deployed","chart":"openfaas-6.1.0","app_version":"0.9.0"},
+{"name":"cert-manager","namespace":"cert-manager","revision":"1","status":"deployed","chart":"cert-manager-v1.0.4","app_version":"v1.0.4"}]`)
+
+	got, err := parseHelmReleases(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []types.Component{
+		{Name: "openfaas", Namespace: "openfaas", Chart: "openfaas-6.1.0", Version: "0.9.0"},
+		{Name: "cert-manager", Namespace: "cert-manager", Chart: "cert-manager-v1.0.4", Version: "v1.0.4"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want %d components, got: %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("component %d want: %+v, got: %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func Test_parseHelmReleases_Empty(t *testing.T) {
+	got, err := parseHelmReleases([]byte("\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("want no components, got: %v", got)
+	}
+}
+
+func Test_AppliedPlanAndObjectsShareHash(t *testing.T) {
+	merged := types.Plan{
+		SCM:        types.GitHubSCM,
+		RootDomain: "example.com",
+		Registry:   "docker.io/ofctest",
+		TLS:        true,
+		TLSConfig:  types.TLSConfig{Mode: types.CustomTLSMode, CertFile: "tls.crt", KeyFile: "tls.key"},
+	}
+
+	processed, err := filterFeatures(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	processed.Registry = "docker.io/ofctest/"
+	processed.Secrets = append(processed.Secrets, processed.CustomTLSSecrets()...)
+
+	labels := types.StandardLabels(processed)
+	applied := newAppliedPlan(merged, nil, nil, labels)
+	manifest, err := types.BuildAppliedPlanConfigMap(applied, labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configMap := struct {
+		Metadata struct {
+			Labels map[string]string `yaml:"labels"`
+		} `yaml:"metadata"`
+	}{}
+	if err := yaml.Unmarshal(manifest, &configMap); err != nil {
+		t.Fatal(err)
+	}
+
+	want := types.PlanHash(processed)
+	if got := configMap.Metadata.Labels[types.PlanHashLabel]; got != want {
+		t.Errorf("ConfigMap plan-hash want: %s, got: %s", want, got)
+	}
+	if applied.PlanHash != want {
+		t.Errorf("applied plan-hash want: %s, got: %s", want, applied.PlanHash)
+	}
+
+	for _, task := range types.BuildSecretMetadataTasks(processed.Secrets[0], labels) {
+		if task.Args[0] == "label" && !strings.Contains(strings.Join(task.Args, " "), types.PlanHashLabel+"="+want) {
+			t.Errorf("secret plan-hash want: %s, got: %v", want, task.Args)
+		}
+	}
+}
+
+func Test_newAppliedPlan_RedactsInterpolatedSecrets(t *testing.T) {
+	plan := types.Plan{
+		RootDomain:   "example.com",
+		CustomersURL: "https://example.com/customers?token=t0ken",
+		Secrets: []types.KeyValueNamespaceTuple{{
+			Name:     "customers-token",
+			Literals: []types.KeyValueTuple{{Name: "token", Value: "t0ken"}, {Name: "plain", Value: "pa55"}},
+		}},
+		Profiles: map[string]types.Plan{
+			"prod": {CustomersURL: "https://example.com/prod?token=t0ken"},
+		},
+	}
+	planFiles := []types.PlanFile{{Name: "init.yaml", Source: "init.yaml", Sensitive: []string{"t0ken"}}}
+
+	applied := newAppliedPlan(plan, planFiles, nil, nil)
+	manifest, err := types.BuildAppliedPlanConfigMap(applied, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, value := range []string{"t0ken", "pa55"} {
+		if strings.Contains(string(manifest), value) {
+			t.Errorf("want %q kept out of the ConfigMap, got:\n%s", value, string(manifest))
+		}
+	}
+	if got := applied.Plan.CustomersURL; got != "https://example.com/customers?token=<redacted>" {
+		t.Errorf("want the interpolated value redacted, got: %s", got)
+	}
+	if got := applied.Plan.RootDomain; got != "example.com" {
+		t.Errorf("want other values kept, got: %s", got)
+	}
+}
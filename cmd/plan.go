@@ -0,0 +1,235 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/openfaas/ofc-bootstrap/pkg/source"
+	"github.com/openfaas/ofc-bootstrap/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCommand.AddCommand(planCmd)
+	planCmd.AddCommand(planDiffCmd)
+
+	planDiffCmd.Flags().StringArrayP("file", "f", []string{}, "The old and new plan files, or only the new plan to compare with the last-applied plan. With --from or --to, the base plan files shared by both sides")
+	planDiffCmd.Flags().StringArray("from", []string{}, "The plan files of the old side, merged in order after any --file")
+	planDiffCmd.Flags().StringArray("to", []string{}, "The plan files of the new side, merged in order after any --file")
+	planDiffCmd.Flags().String("profile", "", "Merge the named overlay from the profiles of each plan")
+}
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Inspect plan files",
+}
+
+var planDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare two plans, or a plan with the last-applied plan",
+	Long: `Compare the fields and secrets of two plans and list the steps of apply
+which would be affected. When only one plan is given, it is compared with
+the plan recorded in the cluster by the last successful apply. The values
+of secrets are never printed.
+
+Use --from and --to to compare sets of plan files, such as a base plan
+with two versions of an overlay. Any --file is then a base shared by both
+sides. Without --from, the new side is compared with the last-applied plan.`,
+	Example: `  ofc-bootstrap plan diff -f old.yaml -f new.yaml
+  ofc-bootstrap plan diff -f init.yaml
+  ofc-bootstrap plan diff -f init.yaml --from prod.yaml --to prod-next.yaml
+  ofc-bootstrap plan diff --from init.yaml --from prod.yaml --to init-next.yaml --to prod.yaml`,
+	RunE:         runPlanDiffE,
+	SilenceUsage: true,
+}
+
+// loadPlans reads each plan file given via --file and merges them in
+// order with types.MergePlanFiles, along with the --profile if given
+func loadPlans(files []string, profile string) (*types.Plan, error) {
+	planFiles, err := readPlanFiles(files, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return types.MergePlanFiles(planFiles, profile)
+}
+
+// readPlanFiles reads the plan files given via --file, in order, and
+// interpolates any environment variables. Each file may be a path, "-"
+// for stdin, a URL or a git source, see source.Read. With strictEnv, an
+// undefined variable is an error.
+func readPlanFiles(files []string, strictEnv bool) ([]types.PlanFile, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("provide one or more --file arguments")
+	}
+
+	stdin := 0
+	for _, yamlFile := range files {
+		if yamlFile == source.Stdin {
+			stdin++
+		}
+	}
+	if stdin > 1 {
+		return nil, fmt.Errorf("--file %s can only be given once", source.Stdin)
+	}
+
+	planFiles := []types.PlanFile{}
+	for _, yamlFile := range files {
+
+		file, err := source.Read(yamlFile, os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("loading --file %s gave error: %s", yamlFile, err.Error())
+		}
+
+		interpolated, sensitive, err := types.InterpolateEnv(file.Data, os.LookupEnv, strictEnv)
+		if err != nil {
+			return nil, fmt.Errorf("interpolating --file %s gave error: %s", yamlFile, err.Error())
+		}
+
+		log.Printf("%s loaded\n", file.Summary())
+		planFiles = append(planFiles, types.PlanFile{
+			Name:      yamlFile,
+			Data:      interpolated,
+			Sensitive: sensitive,
+			Source:    file.Summary(),
+		})
+	}
+
+	log.Printf("Loaded %d plan(s)\n", len(files))
+	return planFiles, nil
+}
+
+func runPlanDiffE(command *cobra.Command, _ []string) error {
+	files, err := command.Flags().GetStringArray("file")
+	if err != nil {
+		return err
+	}
+	from, err := command.Flags().GetStringArray("from")
+	if err != nil {
+		return err
+	}
+	to, err := command.Flags().GetStringArray("to")
+	if err != nil {
+		return err
+	}
+	profile, err := command.Flags().GetString("profile")
+	if err != nil {
+		return err
+	}
+
+	oldFiles, newFiles, err := planDiffSides(files, from, to)
+	if err != nil {
+		return err
+	}
+
+	newPlan, sensitive, err := loadDiffPlan(newFiles, profile)
+	if err != nil {
+		return err
+	}
+
+	var oldPlan types.Plan
+	if len(oldFiles) == 0 {
+		applied, err := readAppliedPlan()
+		if err != nil {
+			return err
+		}
+		oldPlan = applied.Plan
+
+		// The last-applied plan is stored redacted and with no secret
+		// values to compare with, see newAppliedPlan
+		newPlan = types.StripSecretValues(types.RedactedPlan(newPlan, sensitive))
+	} else {
+		var oldSensitive []string
+		oldPlan, oldSensitive, err = loadDiffPlan(oldFiles, profile)
+		if err != nil {
+			return err
+		}
+		sensitive = append(sensitive, oldSensitive...)
+	}
+
+	// The plans are compared with their values, so that a change to an
+	// interpolated secret is found, and only the output is redacted
+	changes, err := types.DiffPlans(oldPlan, newPlan)
+	if err != nil {
+		return err
+	}
+	changes = types.RedactPlanChanges(changes, sensitive)
+
+	if len(changes) == 0 {
+		fmt.Println("No changes.")
+		return nil
+	}
+
+	fmt.Print(formatPlanDiff(changes))
+	return fmt.Errorf("%d change(s) between the plans", len(changes))
+}
+
+// planDiffSides resolves the plan files of the old and new side of a
+// diff. No old files means the new side is compared with the last-applied
+// plan.
+func planDiffSides(files, from, to []string) ([]string, []string, error) {
+	if len(from) == 0 && len(to) == 0 {
+		switch len(files) {
+		case 1:
+			return nil, files, nil
+		case 2:
+			return files[:1], files[1:], nil
+		default:
+			return nil, nil, fmt.Errorf("give one --file to compare with the last-applied plan, or two to compare with each other, or use --from and --to")
+		}
+	}
+
+	if len(to) == 0 {
+		return nil, nil, fmt.Errorf("give the plan files of the new side with --to")
+	}
+
+	newFiles := append(append([]string{}, files...), to...)
+	if len(from) == 0 {
+		return nil, newFiles, nil
+	}
+	oldFiles := append(append([]string{}, files...), from...)
+	for _, file := range oldFiles {
+		if file == source.Stdin && containsString(newFiles, source.Stdin) {
+			return nil, nil, fmt.Errorf("--file %s can only be read by one side of the diff", source.Stdin)
+		}
+	}
+	return oldFiles, newFiles, nil
+}
+
+// loadDiffPlan reads and merges the plan files of one side of a diff, and
+// returns the values interpolated from the environment which are sensitive
+func loadDiffPlan(files []string, profile string) (types.Plan, []string, error) {
+	planFiles, err := readPlanFiles(files, false)
+	if err != nil {
+		return types.Plan{}, nil, err
+	}
+
+	plan, err := types.MergePlanFiles(planFiles, profile)
+	if err != nil {
+		return types.Plan{}, nil, err
+	}
+
+	sensitive := []string{}
+	for _, planFile := range planFiles {
+		sensitive = append(sensitive, planFile.Sensitive...)
+	}
+	return *plan, sensitive, nil
+}
+
+func formatPlanDiff(changes []types.PlanChange) string {
+	buf := bytes.Buffer{}
+	for _, change := range changes {
+		fmt.Fprintf(&buf, "%s\n", change)
+	}
+
+	fmt.Fprintf(&buf, "\nAffected steps:\n")
+	for _, step := range types.AffectedSteps(changes) {
+		fmt.Fprintf(&buf, "  - %s\n", step)
+	}
+	return buf.String()
+}
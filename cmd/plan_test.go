@@ -0,0 +1,183 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openfaas/ofc-bootstrap/pkg/types"
+)
+
+func Test_planDiffSides(t *testing.T) {
+	tests := []struct {
+		title   string
+		files   []string
+		from    []string
+		to      []string
+		wantOld []string
+		wantNew []string
+		wantErr string
+	}{
+		{
+			title:   "one file is compared with the last-applied plan",
+			files:   []string{"init.yaml"},
+			wantNew: []string{"init.yaml"},
+		},
+		{
+			title:   "two files are compared with each other",
+			files:   []string{"old.yaml", "new.yaml"},
+			wantOld: []string{"old.yaml"},
+			wantNew: []string{"new.yaml"},
+		},
+		{
+			title:   "a shared base with two overlays",
+			files:   []string{"init.yaml"},
+			from:    []string{"prod.yaml"},
+			to:      []string{"prod-next.yaml"},
+			wantOld: []string{"init.yaml", "prod.yaml"},
+			wantNew: []string{"init.yaml", "prod-next.yaml"},
+		},
+		{
+			title:   "file lists without a base",
+			from:    []string{"init.yaml", "prod.yaml"},
+			to:      []string{"init-next.yaml", "prod.yaml"},
+			wantOld: []string{"init.yaml", "prod.yaml"},
+			wantNew: []string{"init-next.yaml", "prod.yaml"},
+		},
+		{
+			title:   "a base with an overlay is compared with the last-applied plan",
+			files:   []string{"init.yaml"},
+			to:      []string{"prod.yaml"},
+			wantNew: []string{"init.yaml", "prod.yaml"},
+		},
+		{
+			title:   "--from needs --to",
+			files:   []string{"init.yaml"},
+			from:    []string{"prod.yaml"},
+			wantErr: "--to",
+		},
+		{
+			title:   "stdin cannot be read by both sides",
+			files:   []string{"-"},
+			from:    []string{"prod.yaml"},
+			to:      []string{"prod-next.yaml"},
+			wantErr: "one side",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			gotOld, gotNew, err := planDiffSides(test.files, test.from, test.to)
+			if len(test.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("want error containing %q, got: %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(gotOld) != len(test.wantOld) || (len(gotOld) > 0 && !reflect.DeepEqual(gotOld, test.wantOld)) {
+				t.Errorf("old side want: %v, got: %v", test.wantOld, gotOld)
+			}
+			if !reflect.DeepEqual(gotNew, test.wantNew) {
+				t.Errorf("new side want: %v, got: %v", test.wantNew, gotNew)
+			}
+		})
+	}
+}
+
+func Test_loadDiffPlan_BaseWithOverlays(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plan-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		ioutil.WriteFile(path, []byte(data), 0600)
+		return path
+	}
+	base := write("init.yaml", "root_domain: ofc.example.com\nscale_to_zero: false\n")
+	overlay := write("prod.yaml", "scale_to_zero: true\n")
+	overlayNext := write("prod-next.yaml", "scale_to_zero: true\nroot_domain: ofc.example.org\n")
+
+	oldFiles, newFiles, err := planDiffSides([]string{base}, []string{overlay}, []string{overlayNext})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPlan, _, err := loadDiffPlan(oldFiles, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPlan, _, err := loadDiffPlan(newFiles, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := types.DiffPlans(oldPlan, newPlan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || !strings.Contains(changes[0].String(), "root_domain") {
+		t.Errorf("want only root_domain changed, got: %v", changes)
+	}
+}
+
+func Test_loadDiffPlan_InterpolatedSecretChanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plan-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.Setenv("OFC_TEST_OLD_PASSWORD", "old-pa55")
+	os.Setenv("OFC_TEST_NEW_PASSWORD", "new-pa55")
+	defer os.Unsetenv("OFC_TEST_OLD_PASSWORD")
+	defer os.Unsetenv("OFC_TEST_NEW_PASSWORD")
+
+	secret := `secrets:
+- name: basic-auth
+  literals:
+  - name: basic-auth-password
+    value: ${%s}
+`
+	oldFile := filepath.Join(dir, "old.yaml")
+	newFile := filepath.Join(dir, "new.yaml")
+	ioutil.WriteFile(oldFile, []byte(fmt.Sprintf(secret, "OFC_TEST_OLD_PASSWORD")), 0600)
+	ioutil.WriteFile(newFile, []byte(fmt.Sprintf(secret, "OFC_TEST_NEW_PASSWORD")), 0600)
+
+	oldPlan, oldSensitive, err := loadDiffPlan([]string{oldFile}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPlan, newSensitive, err := loadDiffPlan([]string{newFile}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := types.DiffPlans(oldPlan, newPlan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes = types.RedactPlanChanges(changes, append(oldSensitive, newSensitive...))
+
+	out := formatPlanDiff(changes)
+	if strings.Contains(out, "pa55") {
+		t.Errorf("want secret values redacted, got:\n%s", out)
+	}
+	if !strings.Contains(out, "secrets[basic-auth].literals[basic-auth-password].value: <redacted> (changed)") {
+		t.Errorf("want the secret reported as changed, got:\n%s", out)
+	}
+	if !strings.Contains(out, "  - secrets\n") {
+		t.Errorf("want the secrets step affected, got:\n%s", out)
+	}
+}
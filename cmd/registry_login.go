@@ -10,12 +10,36 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/openfaas/ofc-bootstrap/pkg/types"
+	"github.com/openfaas/ofc-bootstrap/pkg/validators"
 	"github.com/spf13/cobra"
 )
 
+const defaultRegistryAuthFile = "./credentials/config.json"
+
 var registryLoginCommand = &cobra.Command{
-	Use:          "registry-login",
-	Short:        "Generate and save the registry authentication file",
+	Use:   "registry-login",
+	Short: "Generate and save the registry authentication file",
+	Long: `Generate and save the registry authentication file. Entries are merged
+into an existing file, so run the command once for each registry, such as the
+one to push to and one to pull base images from. Use --remove to delete the
+entry for a server.
+
+With --from-docker-config, the credentials are imported from the config.json
+of the Docker CLI, including those held by a credential helper such as
+docker-credential-desktop or docker-credential-osxkeychain, for the registry
+of the plan given via --file, or for --server.
+
+With --provider, the server is derived for a managed registry and its inputs
+are validated, such as the JSON key of a service account for gcr and gar.
+The registry of the plan given via --file is checked against the provider.
+Providers: ghcr, gcr, gar, acr and quay.`,
+	Example: `  ofc-bootstrap registry-login -u ofctest --password-stdin
+  ofc-bootstrap registry-login -u team --password-stdin --server ghcr.io
+  ofc-bootstrap registry-login --remove ghcr.io
+  ofc-bootstrap registry-login --from-docker-config -f init.yaml
+  ofc-bootstrap registry-login --provider gar --location europe-west1 --key-file key.json -f init.yaml
+  ofc-bootstrap registry-login --provider acr --registry-name ofcprod -u <app-id> --password-stdin`,
 	SilenceUsage: true,
 	RunE:         generateRegistryAuthFile,
 }
@@ -28,7 +52,19 @@ func init() {
 	registryLoginCommand.Flags().String("password", "", "The registry password")
 	registryLoginCommand.Flags().BoolP("password-stdin", "s", false, "Reads the docker password from stdin, either pipe to the command or remember to press ctrl+d when reading interactively")
 
-	registryLoginCommand.Flags().Bool("ecr", false, "If we are using ECR we need a different set of flags, so if this is set, we need to set --username and --password")
+	registryLoginCommand.Flags().StringP("output", "o", defaultRegistryAuthFile, "The file to merge the credentials into")
+	registryLoginCommand.Flags().String("remove", "", "Remove the entry for this server from the file")
+
+	registryLoginCommand.Flags().Bool("from-docker-config", false, "Import the credentials from the Docker CLI config and its credential helper")
+	registryLoginCommand.Flags().String("docker-config", "", "The Docker CLI config to import from, defaults to $DOCKER_CONFIG/config.json or ~/.docker/config.json")
+	registryLoginCommand.Flags().StringArrayP("file", "f", []string{}, "The plan whose registry is imported with --from-docker-config, or checked with --provider and --ecr")
+
+	registryLoginCommand.Flags().String("provider", "", "A managed registry: ghcr, gcr, gar, acr or quay")
+	registryLoginCommand.Flags().String("key-file", "", "The service account JSON key for --provider gcr or gar")
+	registryLoginCommand.Flags().String("location", "", "The location for --provider gcr (us, eu, asia) or gar (i.e. europe-west1)")
+	registryLoginCommand.Flags().String("registry-name", "", "The registry name for --provider acr, without .azurecr.io")
+
+	registryLoginCommand.Flags().Bool("ecr", false, "Use the ecr-login credential helper for AWS ECR, set --account-id and --region instead of --username and --password")
 	registryLoginCommand.Flags().String("account-id", "", "Your AWS Account id")
 	registryLoginCommand.Flags().String("region", "", "Your AWS region")
 }
@@ -41,53 +77,178 @@ func generateRegistryAuthFile(command *cobra.Command, _ []string) error {
 	password, _ := command.Flags().GetString("password")
 	server, _ := command.Flags().GetString("server")
 	passStdIn, _ := command.Flags().GetBool("password-stdin")
+	output, _ := command.Flags().GetString("output")
+	remove, _ := command.Flags().GetString("remove")
+
+	if len(remove) > 0 {
+		if err := removeRegistryAuth(output, remove); err != nil {
+			return err
+		}
+		fmt.Printf("\nRemoved %s from %s..OK\n", remove, output)
+		return nil
+	}
+
+	files, _ := command.Flags().GetStringArray("file")
+	var planRegistry *types.RegistryPrefix
+	if len(files) > 0 {
+		plan, err := loadPlans(files, "")
+		if err != nil {
+			return err
+		}
+		registry, err := plan.RegistryPrefix()
+		if err != nil {
+			return err
+		}
+		planRegistry = &registry
+	}
+
+	fromDockerConfig, _ := command.Flags().GetBool("from-docker-config")
+	if fromDockerConfig {
+		dockerConfig, _ := command.Flags().GetString("docker-config")
+
+		host := validators.ServerHost(server)
+		if planRegistry != nil {
+			host = planRegistry.Domain()
+		}
+
+		if err := importDockerConfig(output, dockerConfig, host, validators.RunCredentialHelper); err != nil {
+			return err
+		}
+		fmt.Printf("\nWrote %s..OK\n", output)
+		return nil
+	}
+
+	if passStdIn {
+		fmt.Printf("Enter your password, hit enter then type Ctrl+D\n\nPassword: ")
+		passwordStdin, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		password = strings.TrimSpace(string(passwordStdin))
+	}
+
+	providerName, _ := command.Flags().GetString("provider")
+	if len(providerName) > 0 {
+		in := providerLogin{Username: username, Password: password}
+		in.KeyFile, _ = command.Flags().GetString("key-file")
+		in.Location, _ = command.Flags().GetString("location")
+		in.RegistryName, _ = command.Flags().GetString("registry-name")
+
+		if err := generateProviderFile(output, providerName, in, planRegistry); err != nil {
+			return err
+		}
+		fmt.Printf("\nWrote %s..OK\n", output)
+		return nil
+	}
 
-	if len(username) == 0 {
+	if !ecrEnabled && len(username) == 0 {
 		return fmt.Errorf("you must give --username (-u)")
 	}
 
 	var generateErr error
 	if ecrEnabled {
-		generateErr = generateECRFile(accountID, region)
+		generateErr = generateECRFile(output, accountID, region, planRegistry)
 	} else {
-		if passStdIn {
-			fmt.Printf("Enter your password, hit enter then type Ctrl+D\n\nPassword: ")
-			passwordStdin, err := ioutil.ReadAll(os.Stdin)
-			if err != nil {
-				return err
-			}
-			generateErr = generateFile(username, strings.TrimSpace(string(passwordStdin)), server)
-		} else {
-			generateErr = generateFile(username, password, server)
-		}
+		generateErr = generateFile(output, username, password, server)
 	}
 
 	if generateErr != nil {
 		return generateErr
 	}
 
-	fmt.Printf("\nWrote ./credentials/config.json..OK\n")
+	fmt.Printf("\nWrote %s..OK\n", output)
+
+	if ecrEnabled {
+		registry := types.RegistryPrefix{Host: fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com", accountID, region)}
+		if planRegistry != nil {
+			registry = *planRegistry
+		}
+		printECRPolicy(registry)
+	}
 
 	return nil
 }
 
-func generateFile(username string, password string, server string) error {
+// printECRPolicy prints the minimal IAM policy for the ECR credentials,
+// or how to get it when the registry is not on ECR
+func printECRPolicy(registry types.RegistryPrefix) {
+	policy, err := validators.ECRPolicy(registry)
+	if err != nil {
+		fmt.Printf("\nSet registry to <account-id>.dkr.ecr.<region>.amazonaws.com/ to see the IAM policy needed by the ECR credentials, or run: ofc-bootstrap registry-login --ecr --account-id <account-id> --region <region>\n")
+		return
+	}
+	fmt.Printf("\nThe IAM user in the ECR credentials needs at least this policy:\n%s\n", policy)
+}
+
+// generateProviderFile writes the credentials for a managed registry,
+// after checking that the plan's registry, if given, is on its server
+func generateProviderFile(output, providerName string, in providerLogin, planRegistry *types.RegistryPrefix) error {
+	provider, err := getRegistryProvider(providerName)
+	if err != nil {
+		return err
+	}
+
+	credentials, err := provider.Login(in)
+	if err != nil {
+		return err
+	}
+
+	if planRegistry != nil {
+		if err := checkProviderRegistry(provider, credentials.ServerURL, *planRegistry); err != nil {
+			return err
+		}
+	}
+
+	return generateFile(output, credentials.Username, credentials.Secret, credentials.ServerURL)
+}
+
+func generateFile(output, username, password, server string) error {
 
 	fileBytes, err := generateRegistryAuth(server, username, password)
 	if err != nil {
 		return err
 	}
-	return writeFileToOFCTmp(fileBytes)
+	return mergeRegistryAuthFile(output, fileBytes)
 }
 
-func generateECRFile(accountID string, region string) error {
+// generateECRFile writes the credHelpers for ECR, after checking that the
+// plan's registry, if given, is for the same account and region
+func generateECRFile(output, accountID, region string, planRegistry *types.RegistryPrefix) error {
 
 	fileBytes, err := generateECRRegistryAuth(accountID, region)
 	if err != nil {
 		return err
 	}
 
-	return writeFileToOFCTmp(fileBytes)
+	if planRegistry != nil {
+		if err := validators.ValidateECRConfig(*planRegistry, region, fileBytes); err != nil {
+			return err
+		}
+	}
+
+	return mergeRegistryAuthFile(output, fileBytes)
+}
+
+// importDockerConfig resolves the credentials for the registry host from
+// the Docker CLI config, through its credential helper if needed, and
+// merges them into the file at output as an auths entry
+func importDockerConfig(output, dockerConfig, host string, run validators.CredentialHelper) error {
+	if len(dockerConfig) == 0 {
+		dockerConfig = validators.DockerConfigPath()
+	}
+
+	configBytes, err := ioutil.ReadFile(dockerConfig)
+	if err != nil {
+		return fmt.Errorf("unable to read the Docker config: %s", err.Error())
+	}
+
+	credentials, err := validators.ResolveCredentials(host, configBytes, run)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported credentials for %s as %s from %s\n", host, credentials.Username, dockerConfig)
+	return generateFile(output, credentials.Username, credentials.Secret, validators.ServerFor(host))
 }
 
 func generateRegistryAuth(server, username, password string) ([]byte, error) {
@@ -124,19 +285,156 @@ func generateECRRegistryAuth(accountID, region string) ([]byte, error) {
 	return registryBytes, err
 }
 
-func writeFileToOFCTmp(fileBytes []byte) error {
-	path := "./credentials"
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		err := os.Mkdir(path, 0744)
-		if err != nil {
-			return err
+// mergeRegistryAuthFile merges generated entries into the file at path,
+// creating it if needed
+func mergeRegistryAuthFile(path string, fileBytes []byte) error {
+	existing, err := readRegistryAuthFile(path)
+	if err != nil {
+		return err
+	}
+
+	merged, err := mergeRegistryAuth(existing, fileBytes)
+	if err != nil {
+		return err
+	}
+	return writeRegistryAuthFile(path, merged)
+}
+
+// mergeRegistryAuth adds the entries of generated to existing. An entry
+// for the same registry host replaces the existing one, even when the
+// server is written differently, i.e. docker.io and
+// https://index.docker.io/v1/. Other fields of the file are kept.
+//
+// A credsStore is only written to a new file. It would otherwise send
+// every registry of the file to one helper, hiding the auths entries, so
+// an existing file only gets the per-host credHelpers entry.
+func mergeRegistryAuth(existing, generated []byte) ([]byte, error) {
+	file := map[string]interface{}{}
+	if len(existing) > 0 {
+		if err := json.Unmarshal(existing, &file); err != nil {
+			return nil, fmt.Errorf("unable to parse the existing registry auth file: %s", err.Error())
 		}
 	}
 
-	writeErr := ioutil.WriteFile(filepath.Join(path, "config.json"), fileBytes, 0744)
+	update := RegistryConfigFile{}
+	if err := json.Unmarshal(generated, &update); err != nil {
+		return nil, err
+	}
 
-	return writeErr
+	auths := map[string]interface{}{}
+	for server, auth := range update.AuthConfigs {
+		auths[server] = auth
+	}
+	mergeRegistryEntries(file, "auths", auths)
+
+	credHelpers := map[string]interface{}{}
+	for server, helper := range update.CredHelpers {
+		credHelpers[server] = helper
+	}
+	mergeRegistryEntries(file, "credHelpers", credHelpers)
+
+	if len(existing) == 0 && len(update.CredsStore) > 0 {
+		file["credsStore"] = update.CredsStore
+	}
+
+	// An ecr-login credsStore written with a new file would hide the
+	// auths added now, the ECR hosts are already in credHelpers
+	if len(auths) > 0 && file["credsStore"] == "ecr-login" {
+		if helpers, _ := file["credHelpers"].(map[string]interface{}); len(helpers) > 0 {
+			delete(file, "credsStore")
+		}
+	}
 
+	return json.MarshalIndent(file, "", " ")
+}
+
+// mergeRegistryEntries sets each entry under field, replacing any entry
+// for the same registry host
+func mergeRegistryEntries(file map[string]interface{}, field string, add map[string]interface{}) {
+	entries, _ := file[field].(map[string]interface{})
+	if entries == nil {
+		entries = map[string]interface{}{}
+	}
+
+	for server, value := range add {
+		removeRegistryEntries(entries, server)
+		entries[server] = value
+	}
+
+	// auths is always written, as apply expects it
+	if len(entries) > 0 || field == "auths" {
+		file[field] = entries
+	}
+}
+
+// removeRegistryAuth deletes the auths and credHelpers entries for the
+// host of server from the file at path
+func removeRegistryAuth(path, server string) error {
+	existing, err := readRegistryAuthFile(path)
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		return fmt.Errorf("no registry auth file found at %s", path)
+	}
+
+	file := map[string]interface{}{}
+	if err := json.Unmarshal(existing, &file); err != nil {
+		return fmt.Errorf("unable to parse the existing registry auth file: %s", err.Error())
+	}
+
+	removed := 0
+	for _, field := range []string{"auths", "credHelpers"} {
+		if entries, ok := file[field].(map[string]interface{}); ok {
+			removed += removeRegistryEntries(entries, server)
+		}
+	}
+	if removed == 0 {
+		return fmt.Errorf("no entry found for %s in %s", server, path)
+	}
+
+	fileBytes, err := json.MarshalIndent(file, "", " ")
+	if err != nil {
+		return err
+	}
+	return writeRegistryAuthFile(path, fileBytes)
+}
+
+// removeRegistryEntries deletes the entries with the same registry host as
+// server and returns how many were deleted
+func removeRegistryEntries(entries map[string]interface{}, server string) int {
+	removed := 0
+	host := validators.ServerHost(server)
+	for key := range entries {
+		if validators.ServerHost(key) == host {
+			delete(entries, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+func readRegistryAuthFile(path string) ([]byte, error) {
+	fileBytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return fileBytes, err
+}
+
+// writeRegistryAuthFile writes the file so that only the current user
+// can read it, since it holds credentials
+func writeRegistryAuthFile(path string, fileBytes []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path, fileBytes, 0600); err != nil {
+		return err
+	}
+
+	// WriteFile keeps the mode of an existing file
+	return os.Chmod(path, 0600)
 }
 
 type Auth struct {
@@ -147,6 +445,14 @@ type RegistryAuth struct {
 	AuthConfigs map[string]Auth `json:"auths"`
 }
 
+// RegistryConfigFile holds the entries of a registry auth file which
+// registry-login can write
+type RegistryConfigFile struct {
+	AuthConfigs map[string]Auth   `json:"auths"`
+	CredHelpers map[string]string `json:"credHelpers,omitempty"`
+	CredsStore  string            `json:"credsStore,omitempty"`
+}
+
 type ECRRegistryAuth struct {
 	CredsStore  string            `json:"credsStore"`
 	CredHelpers map[string]string `json:"credHelpers"`
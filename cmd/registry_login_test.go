@@ -4,7 +4,11 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -148,3 +152,162 @@ func bytesToRegistryStruct(bytes []byte) (RegistryAuth, error) {
 	return obj, err
 
 }
+
+func Test_MergeRegistryAuth_AddsServers(t *testing.T) {
+	existing, _ := generateRegistryAuth("https://index.docker.io/v1/", "docker_user", "docker_password")
+	generated, _ := generateRegistryAuth("ghcr.io", "gh_user", "gh_password")
+
+	mergedBytes, err := mergeRegistryAuth(existing, generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, _ := bytesToRegistryStruct(mergedBytes)
+
+	if len(merged.AuthConfigs) != 2 {
+		t.Fatalf("want 2 auths entries, got: %v", merged.AuthConfigs)
+	}
+	for _, server := range []string{"https://index.docker.io/v1/", "ghcr.io"} {
+		if _, ok := merged.AuthConfigs[server]; !ok {
+			t.Errorf("want entry for %s", server)
+		}
+	}
+}
+
+func Test_MergeRegistryAuth_ReplacesSameHost(t *testing.T) {
+	existing, _ := generateRegistryAuth("https://index.docker.io/v1/", "docker_user", "old_password")
+	generated, _ := generateRegistryAuth("docker.io", "docker_user", "new_password")
+
+	mergedBytes, err := mergeRegistryAuth(existing, generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, _ := bytesToRegistryStruct(mergedBytes)
+
+	want := base64.StdEncoding.EncodeToString([]byte("docker_user:new_password"))
+	if len(merged.AuthConfigs) != 1 || merged.AuthConfigs["docker.io"].Base64AuthString != want {
+		t.Errorf("want a single docker.io entry with the new password, got: %v", merged.AuthConfigs)
+	}
+}
+
+func Test_MergeRegistryAuth_KeepsOtherFields(t *testing.T) {
+	existing := []byte(`{"auths": {}, "credHelpers": {"1234567.dkr.ecr.eu-west-2.amazonaws.com": "ecr-login"}, "credsStore": "ecr-login", "experimental": "enabled"}`)
+	generated, _ := generateRegistryAuth("ghcr.io", "gh_user", "gh_password")
+
+	mergedBytes, err := mergeRegistryAuth(existing, generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged := map[string]interface{}{}
+	json.Unmarshal(mergedBytes, &merged)
+	if merged["experimental"] != "enabled" {
+		t.Errorf("want experimental kept, got: %s", string(mergedBytes))
+	}
+	if helpers, _ := merged["credHelpers"].(map[string]interface{}); len(helpers) != 1 {
+		t.Errorf("want credHelpers kept, got: %s", string(mergedBytes))
+	}
+	if _, ok := merged["credsStore"]; ok {
+		t.Errorf("want the ecr-login credsStore removed so that it does not hide ghcr.io, got: %s", string(mergedBytes))
+	}
+}
+
+func Test_MergeRegistryAuth_ECRIntoExistingFile(t *testing.T) {
+	existing, _ := generateRegistryAuth("https://index.docker.io/v1/", "docker_user", "docker_password")
+	generated, _ := generateECRRegistryAuth("123456789012", "eu-west-1")
+
+	mergedBytes, err := mergeRegistryAuth(existing, generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged := map[string]interface{}{}
+	json.Unmarshal(mergedBytes, &merged)
+	if _, ok := merged["credsStore"]; ok {
+		t.Errorf("want no credsStore in an existing file, got: %s", string(mergedBytes))
+	}
+	helpers, _ := merged["credHelpers"].(map[string]interface{})
+	if helpers["123456789012.dkr.ecr.eu-west-1.amazonaws.com"] != "ecr-login" {
+		t.Errorf("want a credHelpers entry for the ECR host, got: %s", string(mergedBytes))
+	}
+	if auths, _ := merged["auths"].(map[string]interface{}); len(auths) != 1 {
+		t.Errorf("want the Docker Hub auths entry kept, got: %s", string(mergedBytes))
+	}
+
+	fresh, err := mergeRegistryAuth(nil, generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(fresh), `"credsStore": "ecr-login"`) {
+		t.Errorf("want the credsStore in a new file, got: %s", string(fresh))
+	}
+}
+
+func Test_RegistryAuthFile_WriteAndRemove(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registry-login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "credentials", "config.json")
+	if err := generateFile(path, "docker_user", "docker_password", "https://index.docker.io/v1/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := generateFile(path, "gh_user", "gh_password", "ghcr.io"); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("want mode 0600, got: %o", info.Mode().Perm())
+	}
+
+	if err := removeRegistryAuth(path, "https://ghcr.io"); err != nil {
+		t.Fatal(err)
+	}
+	fileBytes, _ := ioutil.ReadFile(path)
+	got, _ := bytesToRegistryStruct(fileBytes)
+	if _, ok := got.AuthConfigs["ghcr.io"]; ok || len(got.AuthConfigs) != 1 {
+		t.Errorf("want only the docker.io entry left, got: %v", got.AuthConfigs)
+	}
+
+	if err := removeRegistryAuth(path, "quay.io"); err == nil {
+		t.Errorf("want an error removing a server which is not in the file")
+	}
+}
+
+func Test_ImportDockerConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registry-login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dockerConfig := filepath.Join(dir, "docker-config.json")
+	ioutil.WriteFile(dockerConfig, []byte(`{"auths": {"https://index.docker.io/v1/": {}}, "credsStore": "desktop"}`), 0600)
+
+	run := func(helper, action, input string) (string, error) {
+		if action == "list" {
+			return `{"https://index.docker.io/v1/": "docker_user"}`, nil
+		}
+		return `{"ServerURL": "https://index.docker.io/v1/", "Username": "docker_user", "Secret": "docker_password"}`, nil
+	}
+
+	output := filepath.Join(dir, "config.json")
+	if err := importDockerConfig(output, dockerConfig, "docker.io", run); err != nil {
+		t.Fatal(err)
+	}
+
+	fileBytes, _ := ioutil.ReadFile(output)
+	got, _ := bytesToRegistryStruct(fileBytes)
+	want := base64.StdEncoding.EncodeToString([]byte("docker_user:docker_password"))
+	if got.AuthConfigs["https://index.docker.io/v1/"].Base64AuthString != want {
+		t.Errorf("want a self-contained auths entry for Docker Hub, got: %s", string(fileBytes))
+	}
+	if strings.Contains(string(fileBytes), "credsStore") {
+		t.Errorf("want no credsStore in the file, got: %s", string(fileBytes))
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/openfaas/ofc-bootstrap/pkg/types"
+	"github.com/openfaas/ofc-bootstrap/pkg/validators"
+)
+
+// jsonKeyUsername is the username for a Google Cloud service account key
+const jsonKeyUsername = "_json_key"
+
+// providerLogin holds the registry-login flags which providers read
+type providerLogin struct {
+	Username     string
+	Password     string
+	KeyFile      string
+	Location     string
+	RegistryName string
+}
+
+// registryProvider derives the server and credentials for a managed
+// registry from its own inputs
+type registryProvider struct {
+	Name        string
+	Description string
+
+	// Namespace is the path the registry plan value needs after the
+	// server, i.e. <owner>/
+	Namespace string
+
+	Login func(in providerLogin) (*validators.Credentials, error)
+}
+
+var gcrLocations = []string{"", "us", "eu", "asia"}
+
+var (
+	guidPattern       = regexp.MustCompile(`^[0-9a-fA-F]{8}-([0-9a-fA-F]{4}-){3}[0-9a-fA-F]{12}$`)
+	acrNamePattern    = regexp.MustCompile(`^[a-zA-Z0-9]{5,50}$`)
+	garLocationRegexp = regexp.MustCompile(`^[a-z]+(-[a-z]+[0-9]*)*$`)
+)
+
+var registryProviders = []registryProvider{
+	{
+		Name:        "ghcr",
+		Description: "GitHub Container Registry with a personal access token",
+		Namespace:   "<owner>/",
+		Login: func(in providerLogin) (*validators.Credentials, error) {
+			if err := requireFlags(map[string]string{"--username": in.Username, "--password or --password-stdin": in.Password}); err != nil {
+				return nil, err
+			}
+			if strings.HasPrefix(in.Password, "gho_") || strings.HasPrefix(in.Password, "ghs_") {
+				return nil, fmt.Errorf("the password for ghcr must be a personal access token with the write:packages scope, not an OAuth or app token")
+			}
+			return &validators.Credentials{ServerURL: "ghcr.io", Username: in.Username, Secret: in.Password}, nil
+		},
+	},
+	{
+		Name:        "gcr",
+		Description: "Google Container Registry with a service account JSON key",
+		Namespace:   "<project>/",
+		Login: func(in providerLogin) (*validators.Credentials, error) {
+			if !containsString(gcrLocations, in.Location) {
+				return nil, fmt.Errorf("--location for gcr must be one of: us, eu, asia, or empty for gcr.io")
+			}
+			key, err := readJSONKey(in)
+			if err != nil {
+				return nil, err
+			}
+			server := "gcr.io"
+			if len(in.Location) > 0 {
+				server = in.Location + ".gcr.io"
+			}
+			return &validators.Credentials{ServerURL: server, Username: jsonKeyUsername, Secret: key}, nil
+		},
+	},
+	{
+		Name:        "gar",
+		Description: "Google Artifact Registry with a service account JSON key",
+		Namespace:   "<project>/<repository>/",
+		Login: func(in providerLogin) (*validators.Credentials, error) {
+			if len(in.Location) == 0 || !garLocationRegexp.MatchString(in.Location) {
+				return nil, fmt.Errorf("--location for gar must be the location of the repository, i.e. europe-west1 or us")
+			}
+			key, err := readJSONKey(in)
+			if err != nil {
+				return nil, err
+			}
+			return &validators.Credentials{ServerURL: in.Location + "-docker.pkg.dev", Username: jsonKeyUsername, Secret: key}, nil
+		},
+	},
+	{
+		Name:        "acr",
+		Description: "Azure Container Registry with a service principal",
+		Namespace:   "",
+		Login: func(in providerLogin) (*validators.Credentials, error) {
+			if err := requireFlags(map[string]string{"--registry-name": in.RegistryName, "--username": in.Username, "--password or --password-stdin": in.Password}); err != nil {
+				return nil, err
+			}
+			if !acrNamePattern.MatchString(in.RegistryName) {
+				return nil, fmt.Errorf("--registry-name must be the name of the registry, 5-50 letters and numbers, without .azurecr.io")
+			}
+			if !guidPattern.MatchString(in.Username) {
+				return nil, fmt.Errorf("--username for acr must be the application (client) ID of the service principal, got: %s", in.Username)
+			}
+			return &validators.Credentials{ServerURL: strings.ToLower(in.RegistryName) + ".azurecr.io", Username: in.Username, Secret: in.Password}, nil
+		},
+	},
+	{
+		Name:        "quay",
+		Description: "Quay.io with a robot account",
+		Namespace:   "<organization>/",
+		Login: func(in providerLogin) (*validators.Credentials, error) {
+			if err := requireFlags(map[string]string{"--username": in.Username, "--password or --password-stdin": in.Password}); err != nil {
+				return nil, err
+			}
+			if !strings.Contains(in.Username, "+") {
+				return nil, fmt.Errorf("--username for quay must be a robot account such as <organization>+<robot>, got: %s", in.Username)
+			}
+			return &validators.Credentials{ServerURL: "quay.io", Username: in.Username, Secret: in.Password}, nil
+		},
+	},
+}
+
+// getRegistryProvider finds a provider by name
+func getRegistryProvider(name string) (registryProvider, error) {
+	names := []string{}
+	for _, provider := range registryProviders {
+		if provider.Name == name {
+			return provider, nil
+		}
+		names = append(names, provider.Name)
+	}
+	return registryProvider{}, fmt.Errorf("unknown --provider %q, use one of: %s", name, strings.Join(names, ", "))
+}
+
+// checkProviderRegistry checks that the registry plan value is on the
+// server of the provider, with the namespace the provider needs
+func checkProviderRegistry(provider registryProvider, server string, registry types.RegistryPrefix) error {
+	if registry.Domain() != server {
+		return fmt.Errorf("registry %q in the plan is not on %s, for --provider %s set registry: %s/%s", registry.String(), server, provider.Name, server, provider.Namespace)
+	}
+
+	want := strings.Count(provider.Namespace, "/")
+	got := 0
+	if len(registry.Namespace) > 0 {
+		got = len(strings.Split(registry.Namespace, "/"))
+	}
+	if got < want {
+		return fmt.Errorf("registry %q in the plan needs a namespace for --provider %s, set registry: %s/%s", registry.String(), provider.Name, server, provider.Namespace)
+	}
+	return nil
+}
+
+// readJSONKey reads a Google Cloud service account key, which is used
+// as the password with the _json_key username
+func readJSONKey(in providerLogin) (string, error) {
+	if len(in.KeyFile) == 0 {
+		return "", fmt.Errorf("give the service account JSON key via --key-file")
+	}
+	if len(in.Username) > 0 && in.Username != jsonKeyUsername {
+		return "", fmt.Errorf("--username is not used with a JSON key, the username is %s", jsonKeyUsername)
+	}
+
+	keyBytes, err := ioutil.ReadFile(in.KeyFile)
+	if err != nil {
+		return "", fmt.Errorf("unable to read --key-file: %s", err.Error())
+	}
+
+	key := struct {
+		Type        string `json:"type"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+	}{}
+	if err := json.Unmarshal(keyBytes, &key); err != nil {
+		return "", fmt.Errorf("--key-file %s is not a JSON key: %s", in.KeyFile, err.Error())
+	}
+	if key.Type != "service_account" || len(key.ClientEmail) == 0 || len(key.PrivateKey) == 0 {
+		return "", fmt.Errorf("--key-file %s is not a service account key, it needs type: service_account, client_email and private_key", in.KeyFile)
+	}
+
+	return strings.TrimSpace(string(keyBytes)), nil
+}
+
+func requireFlags(flags map[string]string) error {
+	missing := []string{}
+	for flag, value := range flags {
+		if len(value) == 0 {
+			missing = append(missing, flag)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("give %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
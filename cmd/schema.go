@@ -0,0 +1,57 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/openfaas/ofc-bootstrap/pkg/types"
+	"github.com/openfaas/ofc-bootstrap/pkg/validators"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCommand.AddCommand(schemaCmd)
+
+	schemaCmd.Flags().StringP("output", "o", "", "Write the schema to a file instead of stdout")
+}
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema for plan files",
+	Long: `Print the JSON Schema for plan files, for use with editors which support
+the YAML language server. Add a comment to the top of your plan:
+
+  # yaml-language-server: $schema=./ofc-bootstrap.schema.json`,
+	Example:      `  ofc-bootstrap schema -o ofc-bootstrap.schema.json`,
+	RunE:         runSchemaE,
+	SilenceUsage: true,
+}
+
+func runSchemaE(command *cobra.Command, _ []string) error {
+	output, _ := command.Flags().GetString("output")
+
+	schema := types.PlanSchema(map[string][]string{
+		"secrets[].files[].format": validators.Formats,
+	})
+
+	out, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+	out = append(out, '\n')
+
+	if len(output) == 0 {
+		fmt.Print(string(out))
+		return nil
+	}
+
+	if err := ioutil.WriteFile(output, out, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", output)
+	return nil
+}
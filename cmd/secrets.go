@@ -0,0 +1,256 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	b64 "encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/alexellis/arkade/pkg/k8s"
+	"github.com/openfaas/ofc-bootstrap/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+const defaultSecretType = "Opaque"
+
+func init() {
+	rootCommand.AddCommand(secretsCmd)
+	secretsCmd.AddCommand(secretsDiffCmd)
+
+	secretsDiffCmd.Flags().StringArrayP("file", "f", []string{""}, "A number of init.yaml plan files")
+	secretsDiffCmd.Flags().String("profile", "", "Merge the named overlay from the profiles of the plan")
+}
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage the secrets defined in a plan",
+}
+
+var secretsDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare the secrets of a plan with those in the cluster",
+	Long: `Compare the names, types, keys and content of the secrets enabled by
+a plan with the secrets in the cluster. Values are compared by their
+hashes and are never printed.`,
+	Example:      `  ofc-bootstrap secrets diff -f init.yaml`,
+	RunE:         runSecretsDiffE,
+	SilenceUsage: true,
+}
+
+// clusterSecret is the subset of a Kubernetes Secret used for comparisons
+type clusterSecret struct {
+	Type string            `json:"type"`
+	Data map[string]string `json:"data"`
+}
+
+// secretDiff records the differences between a secret in
+// the plan and the secret found in the cluster
+type secretDiff struct {
+	Name        string
+	Namespace   string
+	Missing     bool
+	WantType    string
+	GotType     string
+	MissingKeys []string
+	ExtraKeys   []string
+	ChangedKeys []string
+}
+
+func (d secretDiff) changed() bool {
+	return d.Missing ||
+		d.WantType != d.GotType ||
+		len(d.MissingKeys) > 0 ||
+		len(d.ExtraKeys) > 0 ||
+		len(d.ChangedKeys) > 0
+}
+
+func runSecretsDiffE(command *cobra.Command, _ []string) error {
+	files, err := command.Flags().GetStringArray("file")
+	if err != nil {
+		return err
+	}
+
+	profile, err := command.Flags().GetString("profile")
+	if err != nil {
+		return err
+	}
+
+	planMerged, err := loadPlans(files, profile)
+	if err != nil {
+		return err
+	}
+
+	plan, err := filterFeatures(*planMerged)
+	if err != nil {
+		return fmt.Errorf("error while retreiving features: %s", err.Error())
+	}
+
+	changed := 0
+	for _, secret := range enabledSecrets(plan) {
+		live, err := getClusterSecret(secret.Name, secret.Namespace)
+		if err != nil {
+			return err
+		}
+
+		planned, err := plannedSecretData(secret)
+		if err != nil {
+			return err
+		}
+
+		diff := diffSecret(secret, planned, live)
+		if diff.changed() {
+			changed++
+		}
+		fmt.Print(formatSecretDiff(diff))
+	}
+
+	if changed > 0 {
+		return fmt.Errorf("%d secret(s) differ from the plan", changed)
+	}
+
+	fmt.Println("All secrets match the plan.")
+	return nil
+}
+
+func getClusterSecret(name, namespace string) (*clusterSecret, error) {
+	res, err := k8s.KubectlTask("get", "secret", name, "-n", namespace, "-o", "json", "--ignore-not-found")
+	if err != nil {
+		return nil, err
+	}
+
+	if res.ExitCode != 0 {
+		return nil, fmt.Errorf("error getting secret %s/%s: %s", namespace, name, res.Stderr)
+	}
+
+	if len(strings.TrimSpace(res.Stdout)) == 0 {
+		return nil, nil
+	}
+
+	secret := clusterSecret{}
+	if err := json.Unmarshal([]byte(res.Stdout), &secret); err != nil {
+		return nil, fmt.Errorf("unable to parse secret %s/%s: %s", namespace, name, err.Error())
+	}
+	return &secret, nil
+}
+
+// plannedSecretData returns the value of each key of the secret which is
+// known before it is created. Keys with generated values, files which
+// are yet to be generated and keys of existing secrets have a nil value.
+func plannedSecretData(secret types.KeyValueNamespaceTuple) (map[string][]byte, error) {
+	data := map[string][]byte{}
+
+	if secret.Existing {
+		for _, key := range secret.Keys() {
+			data[key] = nil
+		}
+		return data, nil
+	}
+
+	for _, literal := range secret.Literals {
+		if len(literal.Value) > 0 {
+			data[literal.Name] = []byte(literal.Value)
+		} else {
+			data[literal.Name] = nil
+		}
+	}
+
+	for _, file := range secret.Files {
+		fileBytes, err := ioutil.ReadFile(file.ExpandValueFrom())
+		if err != nil {
+			if os.IsNotExist(err) {
+				data[file.Name] = nil
+				continue
+			}
+			return nil, err
+		}
+		data[file.Name] = fileBytes
+	}
+
+	return data, nil
+}
+
+func diffSecret(secret types.KeyValueNamespaceTuple, planned map[string][]byte, live *clusterSecret) secretDiff {
+	diff := secretDiff{
+		Name:      secret.Name,
+		Namespace: secret.Namespace,
+		WantType:  secret.Type,
+	}
+	if len(diff.WantType) == 0 {
+		diff.WantType = defaultSecretType
+	}
+
+	if live == nil {
+		diff.Missing = true
+		diff.GotType = diff.WantType
+		return diff
+	}
+	diff.GotType = live.Type
+
+	for key, value := range planned {
+		encoded, ok := live.Data[key]
+		if !ok {
+			diff.MissingKeys = append(diff.MissingKeys, key)
+			continue
+		}
+
+		if value == nil {
+			continue
+		}
+
+		decoded, err := b64.StdEncoding.DecodeString(encoded)
+		if err != nil || sha256.Sum256(decoded) != sha256.Sum256(value) {
+			diff.ChangedKeys = append(diff.ChangedKeys, key)
+		}
+	}
+
+	for key := range live.Data {
+		if _, ok := planned[key]; !ok {
+			diff.ExtraKeys = append(diff.ExtraKeys, key)
+		}
+	}
+
+	sort.Strings(diff.MissingKeys)
+	sort.Strings(diff.ExtraKeys)
+	sort.Strings(diff.ChangedKeys)
+
+	return diff
+}
+
+func formatSecretDiff(diff secretDiff) string {
+	buf := bytes.Buffer{}
+	name := diff.Namespace + "/" + diff.Name
+
+	if diff.Missing {
+		fmt.Fprintf(&buf, "- %s: missing in cluster\n", name)
+		return buf.String()
+	}
+
+	if !diff.changed() {
+		fmt.Fprintf(&buf, "  %s: matches\n", name)
+		return buf.String()
+	}
+
+	fmt.Fprintf(&buf, "~ %s:\n", name)
+	if diff.WantType != diff.GotType {
+		fmt.Fprintf(&buf, "    type: %s in cluster, %s in plan\n", diff.GotType, diff.WantType)
+	}
+	for _, key := range diff.MissingKeys {
+		fmt.Fprintf(&buf, "    - %s: missing in cluster\n", key)
+	}
+	for _, key := range diff.ExtraKeys {
+		fmt.Fprintf(&buf, "    + %s: not in plan\n", key)
+	}
+	for _, key := range diff.ChangedKeys {
+		fmt.Fprintf(&buf, "    ~ %s: content differs\n", key)
+	}
+
+	return buf.String()
+}
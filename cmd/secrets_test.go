@@ -0,0 +1,120 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package cmd
+
+import (
+	b64 "encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openfaas/ofc-bootstrap/pkg/types"
+)
+
+func Test_diffSecret_MissingInCluster(t *testing.T) {
+	secret := types.KeyValueNamespaceTuple{Name: "basic-auth", Namespace: "openfaas"}
+
+	diff := diffSecret(secret, map[string][]byte{}, nil)
+	if !diff.Missing || !diff.changed() {
+		t.Errorf("want secret to be reported as missing, got: %+v", diff)
+	}
+}
+
+func Test_diffSecret_Matches(t *testing.T) {
+	secret := types.KeyValueNamespaceTuple{Name: "basic-auth", Namespace: "openfaas"}
+	planned := map[string][]byte{
+		"basic-auth-user":     []byte("admin"),
+		"basic-auth-password": nil,
+	}
+	live := &clusterSecret{
+		Type: "Opaque",
+		Data: map[string]string{
+			"basic-auth-user":     b64.StdEncoding.EncodeToString([]byte("admin")),
+			"basic-auth-password": b64.StdEncoding.EncodeToString([]byte("generated")),
+		},
+	}
+
+	diff := diffSecret(secret, planned, live)
+	if diff.changed() {
+		t.Errorf("want no changes, got: %+v", diff)
+	}
+}
+
+func Test_diffSecret_KeysTypeAndContent(t *testing.T) {
+	secret := types.KeyValueNamespaceTuple{
+		Name:      "registry-pull-secret",
+		Namespace: "openfaas-fn",
+		Type:      "kubernetes.io/dockerconfigjson",
+	}
+	planned := map[string][]byte{
+		".dockerconfigjson": []byte(`{"auths": {}}`),
+		"extra":             []byte("value"),
+	}
+	live := &clusterSecret{
+		Type: "Opaque",
+		Data: map[string]string{
+			".dockerconfigjson": b64.StdEncoding.EncodeToString([]byte(`{}`)),
+			"config.json":       b64.StdEncoding.EncodeToString([]byte(`{}`)),
+		},
+	}
+
+	diff := diffSecret(secret, planned, live)
+
+	if diff.GotType != "Opaque" || diff.WantType != secret.Type {
+		t.Errorf("want type difference, got: %s / %s", diff.GotType, diff.WantType)
+	}
+	if !reflect.DeepEqual(diff.MissingKeys, []string{"extra"}) {
+		t.Errorf("want missing keys: [extra], got: %v", diff.MissingKeys)
+	}
+	if !reflect.DeepEqual(diff.ExtraKeys, []string{"config.json"}) {
+		t.Errorf("want extra keys: [config.json], got: %v", diff.ExtraKeys)
+	}
+	if !reflect.DeepEqual(diff.ChangedKeys, []string{".dockerconfigjson"}) {
+		t.Errorf("want changed keys: [.dockerconfigjson], got: %v", diff.ChangedKeys)
+	}
+}
+
+func Test_formatSecretDiff_NeverPrintsValues(t *testing.T) {
+	secret := types.KeyValueNamespaceTuple{Name: "payload-secret", Namespace: "openfaas"}
+	planned := map[string][]byte{"payload-secret": []byte("plan-value")}
+	live := &clusterSecret{
+		Type: "Opaque",
+		Data: map[string]string{"payload-secret": b64.StdEncoding.EncodeToString([]byte("cluster-value"))},
+	}
+
+	got := formatSecretDiff(diffSecret(secret, planned, live))
+
+	if !strings.Contains(got, "payload-secret: content differs") {
+		t.Errorf("want content difference in output, got: %q", got)
+	}
+	for _, value := range []string{"plan-value", "cluster-value", live.Data["payload-secret"]} {
+		if strings.Contains(got, value) {
+			t.Errorf("output must not contain value %q, got: %q", value, got)
+		}
+	}
+}
+
+func Test_plannedSecretData_ExistingSecretComparesKeysOnly(t *testing.T) {
+	secret := types.KeyValueNamespaceTuple{
+		Name:      "registry-secret",
+		Namespace: "openfaas",
+		Existing:  true,
+		Files:     []types.FileSecret{{Name: "config.json", ValueFrom: "./does-not-exist.json"}},
+	}
+
+	planned, err := plannedSecretData(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	live := &clusterSecret{
+		Type: "Opaque",
+		Data: map[string]string{"config.json": b64.StdEncoding.EncodeToString([]byte(`{"auths": {}}`))},
+	}
+
+	diff := diffSecret(secret, planned, live)
+	if diff.changed() {
+		t.Errorf("want no changes for an existing secret with all keys, got: %+v", diff)
+	}
+}
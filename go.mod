@@ -4,14 +4,13 @@ go 1.13
 
 require (
 	code.cloudfoundry.org/bytefmt v0.0.0-20200131002437-cf55d5288a48 // indirect
+	filippo.io/age v1.0.0
 	github.com/alexellis/arkade v0.0.0-20201213184027-cc231f9508b1
 	github.com/alexellis/derek v0.0.0-20201203223145-52084a5968ea // indirect
 	github.com/alexellis/go-execute v0.0.0-20201205082949-69a2cde04f4f
 	github.com/bitnami-labs/sealed-secrets v0.13.1 // indirect
 	github.com/containerd/continuity v0.0.0-20201208142359-180525291bb7 // indirect
-	github.com/imdario/mergo v0.3.11
 	github.com/inlets/inletsctl v0.0.0-20200211123457-caff14436308
-	github.com/jinzhu/copier v0.0.0-20190924061706-b57f9002281a
 	github.com/minio/minio-go v6.0.14+incompatible // indirect
 	github.com/moby/buildkit v0.8.1 // indirect
 	github.com/morikuni/aec v1.0.0
@@ -16,17 +16,29 @@ type IngressTemplate struct {
 	RootDomain string
 	TLS        bool
 	IssuerType string
+
+	Challenge string
+	// Hosts have their own certificate with the http01 challenge
+	Hosts []string
 }
 
 // Apply templates and applies any ingress records required
-// for the OpenFaaS Cloud ingress configuration
-func Apply(plan types.Plan) error {
+// for the OpenFaaS Cloud ingress configuration, labelled with labels
+func Apply(plan types.Plan, labels map[string]string) error {
+
+	challenge := plan.TLSConfig.ChallengeType()
+	if plan.TLSConfig.TLSMode() == types.CustomTLSMode {
+		// a custom certificate is a wildcard, as with dns01
+		challenge = types.DNS01Challenge
+	}
 
 	if err := apply("ingress-wildcard.yml", "ingress-wildcard", IngressTemplate{
 		RootDomain: plan.RootDomain,
 		TLS:        plan.TLS,
 		IssuerType: plan.TLSConfig.IssuerType,
-	}); err != nil {
+		Challenge:  challenge,
+		Hosts:      plan.TLSHosts(),
+	}, labels); err != nil {
 		return err
 	}
 
@@ -34,14 +46,14 @@ func Apply(plan types.Plan) error {
 		RootDomain: plan.RootDomain,
 		TLS:        plan.TLS,
 		IssuerType: plan.TLSConfig.IssuerType,
-	}); err != nil {
+	}, labels); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func apply(source string, name string, ingress IngressTemplate) error {
+func apply(source string, name string, ingress IngressTemplate, labels map[string]string) error {
 
 	generatedData, err := applyTemplate("templates/k8s/"+source, ingress)
 	if err != nil {
@@ -76,6 +88,14 @@ func apply(source string, name string, ingress IngressTemplate) error {
 
 	log.Println(execRes.Stdout, execRes.Stderr)
 
+	labelTask := types.BuildLabelFileTask(tempFilePath, labels)
+	labelRes, err := labelTask.Execute()
+	if err != nil {
+		return err
+	}
+
+	log.Println(labelRes.Stdout, labelRes.Stderr)
+
 	return nil
 }
 
@@ -27,3 +27,54 @@ func Test_applyTemplateWithTLS(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func Test_applyTemplateWildcardTLS(t *testing.T) {
+	templateValues := IngressTemplate{
+		RootDomain: "test.com",
+		TLS:        true,
+		Challenge:  "dns01",
+	}
+
+	generatedValue, err := applyTemplate("../../templates/k8s/ingress-wildcard.yml", templateValues)
+	if err != nil {
+		t.Fatalf("expected no error generating template, but got %s", err.Error())
+	}
+
+	want := `  tls:
+  - hosts:
+    - '*.test.com'
+    secretName: wildcard-test.com-cert
+`
+	if !strings.Contains(string(generatedValue), want) {
+		t.Errorf("want generated value to contain: %q, generated was: %q", want, string(generatedValue))
+	}
+}
+
+func Test_applyTemplateHTTP01HostTLS(t *testing.T) {
+	templateValues := IngressTemplate{
+		RootDomain: "test.com",
+		TLS:        true,
+		Challenge:  "http01",
+		Hosts:      []string{"system.test.com", "alexellis.test.com"},
+	}
+
+	generatedValue, err := applyTemplate("../../templates/k8s/ingress-wildcard.yml", templateValues)
+	if err != nil {
+		t.Fatalf("expected no error generating template, but got %s", err.Error())
+	}
+
+	want := `  tls:
+  - hosts:
+    - 'system.test.com'
+    secretName: system.test.com-cert
+  - hosts:
+    - 'alexellis.test.com'
+    secretName: alexellis.test.com-cert
+`
+	if !strings.Contains(string(generatedValue), want) {
+		t.Errorf("want generated value to contain: %q, generated was: %q", want, string(generatedValue))
+	}
+	if strings.Contains(string(generatedValue), "wildcard-test.com-cert") {
+		t.Errorf("want no wildcard certificate with http01, generated was: %q", string(generatedValue))
+	}
+}
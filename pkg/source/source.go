@@ -0,0 +1,246 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+// Package source reads plan files from a local path, stdin, a URL or
+// a file within a git repository.
+package source
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	execute "github.com/alexellis/go-execute/pkg/v1"
+)
+
+const (
+	// Stdin is the name given to read a plan from stdin
+	Stdin = "-"
+
+	// gitPrefix forces a file to be read from a git repository
+	gitPrefix = "git::"
+
+	// checksumParam pins the content of a URL, i.e. ?checksum=sha256:<hex>
+	checksumParam = "checksum"
+)
+
+// HTTPClient is used to download plan files given as a URL
+var HTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// File is a plan file and enough detail to read the same content again
+type File struct {
+	// Name is the source as given i.e. a path, "-" or a URL
+	Name string
+	Data []byte
+
+	// Checksum is the SHA-256 of Data
+	Checksum string
+
+	// Revision is the commit of a file read from a git repository
+	Revision string
+}
+
+// Summary describes the source, its checksum and its revision, if known
+func (f File) Summary() string {
+	details := []string{}
+	if f.Name == Stdin {
+		details = append(details, "stdin")
+	}
+	if len(f.Revision) > 0 {
+		details = append(details, "commit "+f.Revision)
+	}
+	details = append(details, "sha256:"+f.Checksum)
+
+	return fmt.Sprintf("%s (%s)", f.Name, strings.Join(details, ", "))
+}
+
+// Read reads a plan file from stdin when name is "-", from a URL
+// starting with http:// or https://, from a git repository when name
+// starts with "git::", or otherwise from a local path.
+func Read(name string, stdin io.Reader) (*File, error) {
+	var data []byte
+	var revision string
+	var err error
+
+	switch {
+	case name == Stdin:
+		data, err = ioutil.ReadAll(stdin)
+	case strings.HasPrefix(name, gitPrefix):
+		data, revision, err = readGit(name)
+	case strings.HasPrefix(name, "https://"), strings.HasPrefix(name, "http://"):
+		data, err = readURL(name)
+	default:
+		data, err = ioutil.ReadFile(name)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &File{
+		Name:     name,
+		Data:     data,
+		Checksum: checksum(data),
+		Revision: revision,
+	}, nil
+}
+
+// readURL downloads a file and checks it against the checksum given
+// in the query string, which is not sent to the server
+func readURL(rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	query := u.Query()
+	want := query.Get(checksumParam)
+	query.Del(checksumParam)
+	u.RawQuery = query.Encode()
+
+	if len(want) > 0 && !strings.HasPrefix(want, "sha256:") {
+		return nil, fmt.Errorf("unsupported checksum %q, use sha256:<hex>", want)
+	}
+
+	res, err := HTTPClient.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code from %s: %d", u.String(), res.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(want) > 0 {
+		got := checksum(data)
+		if !strings.EqualFold(strings.TrimPrefix(want, "sha256:"), got) {
+			return nil, fmt.Errorf("checksum mismatch for %s, want: %s, got: sha256:%s", u.String(), want, got)
+		}
+	}
+
+	return data, nil
+}
+
+// GitRef is a file within a git repository at a given ref
+type GitRef struct {
+	Repo string
+	Path string
+	Ref  string
+}
+
+// ParseGitRef parses git::<repo>//<path>?ref=<ref>, the ref is optional
+// and defaults to the remote HEAD
+func ParseGitRef(name string) (GitRef, error) {
+	ref := GitRef{}
+
+	value := strings.TrimPrefix(name, gitPrefix)
+	if i := strings.LastIndex(value, "?"); i > -1 {
+		query, err := url.ParseQuery(value[i+1:])
+		if err != nil {
+			return ref, fmt.Errorf("invalid query in %s: %s", name, err.Error())
+		}
+		ref.Ref = query.Get("ref")
+		value = value[:i]
+	}
+
+	// Skip the "//" of a scheme such as https:// or ssh://
+	start := 0
+	if i := strings.Index(value, "://"); i > -1 {
+		start = i + len("://")
+	}
+
+	i := strings.Index(value[start:], "//")
+	if i == -1 {
+		return ref, fmt.Errorf("no path given in %s, use git::<repo>//<path>?ref=<ref>", name)
+	}
+
+	ref.Repo = value[:start+i]
+	ref.Path = value[start+i+len("//"):]
+
+	if len(ref.Repo) == 0 || len(ref.Path) == 0 {
+		return ref, fmt.Errorf("invalid git source %s, use git::<repo>//<path>?ref=<ref>", name)
+	}
+
+	return ref, nil
+}
+
+// readGit fetches the ref from the repository with a shallow clone and
+// returns the file along with the commit it was read from
+func readGit(name string) ([]byte, string, error) {
+	ref, err := ParseGitRef(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	dir, err := ioutil.TempDir(os.TempDir(), "ofc-plan-")
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.RemoveAll(dir)
+
+	fetch := []string{"fetch", "--quiet", "--depth", "1", ref.Repo}
+	if len(ref.Ref) > 0 {
+		fetch = append(fetch, ref.Ref)
+	}
+
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		fetch,
+		{"checkout", "--quiet", "FETCH_HEAD"},
+	} {
+		if _, err := git(dir, args); err != nil {
+			return nil, "", err
+		}
+	}
+
+	revision, err := git(dir, []string{"rev-parse", "HEAD"})
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(ref.Path)))
+	if err != nil {
+		return nil, "", fmt.Errorf("reading %s from %s gave error: %s", ref.Path, ref.Repo, err.Error())
+	}
+
+	return data, revision, nil
+}
+
+func git(dir string, args []string) (string, error) {
+	task := execute.ExecTask{
+		Command:     "git",
+		Args:        args,
+		Cwd:         dir,
+		StreamStdio: false,
+	}
+
+	res, err := task.Execute()
+	if err != nil {
+		return "", err
+	}
+
+	if res.ExitCode != 0 {
+		return "", fmt.Errorf("git %s gave error: %s", args[0], strings.TrimSpace(res.Stderr))
+	}
+
+	return strings.TrimSpace(res.Stdout), nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,185 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package source
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_ParseGitRef(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		want    GitRef
+		wantErr string
+	}{
+		{
+			name:  "https with ref",
+			value: "git::https://git.example.com/infra/plans.git//ofc/base.yaml?ref=v1.2",
+			want:  GitRef{Repo: "https://git.example.com/infra/plans.git", Path: "ofc/base.yaml", Ref: "v1.2"},
+		},
+		{
+			name:  "scp-style without ref",
+			value: "git::git@github.com:infra/plans.git//base.yaml",
+			want:  GitRef{Repo: "git@github.com:infra/plans.git", Path: "base.yaml"},
+		},
+		{
+			name:  "local path",
+			value: "git::/srv/plans//base.yaml?ref=main",
+			want:  GitRef{Repo: "/srv/plans", Path: "base.yaml", Ref: "main"},
+		},
+		{
+			name:    "no path",
+			value:   "git::https://git.example.com/infra/plans.git?ref=v1.2",
+			wantErr: "no path given in git::https://git.example.com/infra/plans.git?ref=v1.2, use git::<repo>//<path>?ref=<ref>",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ParseGitRef(c.value)
+			if len(c.wantErr) > 0 {
+				if err == nil || err.Error() != c.wantErr {
+					t.Fatalf("want error: %s, got: %v", c.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("want no error, got: %s", err.Error())
+			}
+			if got != c.want {
+				t.Errorf("want: %+v, got: %+v", c.want, got)
+			}
+		})
+	}
+}
+
+func Test_Read_Stdin(t *testing.T) {
+	file, err := Read(Stdin, strings.NewReader("root_domain: example.com\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(file.Data) != "root_domain: example.com\n" {
+		t.Errorf("unexpected data: %q", string(file.Data))
+	}
+
+	want := "- (stdin, sha256:" + checksum(file.Data) + ")"
+	if file.Summary() != want {
+		t.Errorf("summary want: %s, got: %s", want, file.Summary())
+	}
+}
+
+func Test_Read_URL(t *testing.T) {
+	body := "root_domain: example.com\n"
+	sum := checksum([]byte(body))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get(checksumParam) != "" {
+			t.Errorf("checksum should not be sent to the server")
+		}
+		if r.URL.Path != "/base.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		name    string
+		url     string
+		wantErr string
+	}{
+		{name: "no checksum", url: server.URL + "/base.yaml"},
+		{name: "matching checksum", url: server.URL + "/base.yaml?checksum=sha256:" + sum},
+		{
+			name:    "mismatched checksum",
+			url:     server.URL + "/base.yaml?checksum=sha256:abc",
+			wantErr: "checksum mismatch for " + server.URL + "/base.yaml, want: sha256:abc, got: sha256:" + sum,
+		},
+		{
+			name:    "unsupported checksum",
+			url:     server.URL + "/base.yaml?checksum=md5:abc",
+			wantErr: `unsupported checksum "md5:abc", use sha256:<hex>`,
+		},
+		{
+			name:    "not found",
+			url:     server.URL + "/missing.yaml",
+			wantErr: "unexpected status code from " + server.URL + "/missing.yaml: 404",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			file, err := Read(c.url, nil)
+			if len(c.wantErr) > 0 {
+				if err == nil || err.Error() != c.wantErr {
+					t.Fatalf("want error: %s, got: %v", c.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("want no error, got: %s", err.Error())
+			}
+			if string(file.Data) != body {
+				t.Errorf("unexpected data: %q", string(file.Data))
+			}
+			if file.Checksum != sum {
+				t.Errorf("checksum want: %s, got: %s", sum, file.Checksum)
+			}
+		})
+	}
+}
+
+func Test_Read_Git(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	repo, err := ioutil.TempDir("", "ofc-plan-repo-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(repo)
+
+	os.MkdirAll(filepath.Join(repo, "ofc"), 0755)
+	ioutil.WriteFile(filepath.Join(repo, "ofc", "base.yaml"), []byte("root_domain: example.com\n"), 0644)
+
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "base"},
+		{"tag", "v1.2"},
+	} {
+		if _, err := git(repo, args); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	commit, err := git(repo, []string{"rev-parse", "HEAD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := Read("git::"+repo+"//ofc/base.yaml?ref=v1.2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(file.Data) != "root_domain: example.com\n" {
+		t.Errorf("unexpected data: %q", string(file.Data))
+	}
+	if file.Revision != commit {
+		t.Errorf("revision want: %s, got: %s", commit, file.Revision)
+	}
+}
@@ -64,6 +64,11 @@ func Apply(plan types.Plan) error {
 		scheme += "s"
 	}
 
+	registry, err := plan.RegistryPrefix()
+	if err != nil {
+		return err
+	}
+
 	customersSecretPath := ""
 
 	if plan.CustomersSecret {
@@ -71,7 +76,7 @@ func Apply(plan types.Plan) error {
 	}
 
 	if gwConfigErr := generateTemplate("gateway_config", plan, gatewayConfig{
-		Registry:             plan.Registry,
+		Registry:             registry.String(),
 		RootDomain:           plan.RootDomain,
 		CustomersURL:         plan.CustomersURL,
 		Scheme:               scheme,
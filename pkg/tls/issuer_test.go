@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"html/template"
 	"io/ioutil"
+	"strings"
 	"testing"
 )
 
@@ -233,4 +234,204 @@ spec:
 	}
 }
 
+func Test_RFC2136_Issuer(t *testing.T) {
+	tlsTemplate := TLSTemplate{
+		Email:         "sales@openfaas.com",
+		IssuerType:    "ClusterIssuer",
+		DNSService:    "rfc2136",
+		Nameserver:    "10.0.0.53:53",
+		TSIGKeyName:   "ofc-key",
+		TSIGAlgorithm: "HMACSHA512",
+	}
+
+	templateData, err := ioutil.ReadFile("../../templates/k8s/tls/issuer-prod.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	templateRes := template.Must(template.New("prod-issuer").Parse(string(templateData)))
+	buf := bytes.Buffer{}
+	if err := templateRes.Execute(&buf, &tlsTemplate); err != nil {
+		t.Fatal(err)
+	}
+
+	wantTemplate := `apiVersion: cert-manager.io/v1alpha2
+kind: ClusterIssuer
+metadata:
+  name: letsencrypt-prod
+  namespace: openfaas
+spec:
+  acme:
+    email: "sales@openfaas.com"
+    server: https://acme-v02.api.letsencrypt.org/directory
+    privateKeySecretRef:
+      name: letsencrypt-prod
+    solvers:
+    - dns01:
+        rfc2136:
+          
+          nameserver: 10.0.0.53:53
+          tsigKeyName: ofc-key
+          tsigAlgorithm: HMACSHA512
+          tsigSecretSecretRef:
+            name: rfc2136-tsig-secret
+            key: tsig-secret-key
+          `
+
+	if got := buf.String(); got != wantTemplate {
+		t.Errorf("Want\n`%q`\n, but got\n`%q`", wantTemplate, got)
+	}
+}
+
+func Test_DNS01_Issuers(t *testing.T) {
+	cases := []struct {
+		tlsTemplate TLSTemplate
+		want        []string
+	}{
+		{
+			tlsTemplate: TLSTemplate{
+				DNSService:     "azuredns",
+				ClientID:       "app-id",
+				SubscriptionID: "sub-id",
+				TenantID:       "tenant-id",
+				ResourceGroup:  "dns-rg",
+				HostedZone:     "example.com",
+			},
+			want: []string{
+				"        azuredns:\n",
+				"          clientID: app-id\n",
+				"            name: azuredns-config\n            key: client-secret\n",
+				"          subscriptionID: sub-id\n",
+				"          tenantID: tenant-id\n",
+				"          resourceGroupName: dns-rg\n",
+				"          hostedZoneName: example.com\n",
+			},
+		},
+		{
+			tlsTemplate: TLSTemplate{DNSService: "acmedns", ACMEDNSHost: "https://auth.acme-dns.io"},
+			want: []string{
+				"        acmedns:\n",
+				"          host: https://auth.acme-dns.io\n",
+				"            name: acme-dns\n            key: acmedns.json\n",
+			},
+		},
+		{
+			tlsTemplate: TLSTemplate{DNSService: "akamai", ServiceConsumerDomain: "akab-abc.luna.akamaiapis.net"},
+			want: []string{
+				"        akamai:\n",
+				"          serviceConsumerDomain: akab-abc.luna.akamaiapis.net\n",
+				"          clientTokenSecretRef:\n            name: akamai-dns\n            key: client-token\n",
+				"          clientSecretSecretRef:\n            name: akamai-dns\n            key: client-secret\n",
+				"          accessTokenSecretRef:\n            name: akamai-dns\n            key: access-token\n",
+			},
+		},
+	}
+
+	for _, issuer := range []string{"issuer-prod.yml", "issuer-staging.yml"} {
+		templateData, err := ioutil.ReadFile("../../templates/k8s/tls/" + issuer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		templateRes := template.Must(template.New(issuer).Parse(string(templateData)))
+
+		for _, c := range cases {
+			buf := bytes.Buffer{}
+			if err := templateRes.Execute(&buf, &c.tlsTemplate); err != nil {
+				t.Fatal(err)
+			}
+
+			got := buf.String()
+			for _, want := range c.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("%s %s: want %q, got:\n%s", issuer, c.tlsTemplate.DNSService, want, got)
+				}
+			}
+		}
+	}
+}
+
+func Test_HTTP01_Issuer(t *testing.T) {
+	tlsTemplate := TLSTemplate{
+		Email:        "sales@openfaas.com",
+		IssuerType:   "staging",
+		Challenge:    "http01",
+		IngressClass: "nginx",
+	}
+
+	templateData, err := ioutil.ReadFile("../../templates/k8s/tls/issuer-staging.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	templateRes := template.Must(template.New("staging-issuer").Parse(string(templateData)))
+	buf := bytes.Buffer{}
+	if err := templateRes.Execute(&buf, &tlsTemplate); err != nil {
+		t.Fatal(err)
+	}
+
+	wantTemplate := `apiVersion: cert-manager.io/v1alpha2
+kind: ClusterIssuer
+metadata:
+  name: letsencrypt-staging
+  namespace: openfaas
+spec:
+  acme:
+    email: "sales@openfaas.com"
+    server: https://acme-staging-v02.api.letsencrypt.org/directory
+    privateKeySecretRef:
+      name: letsencrypt-staging
+    solvers:
+    - http01:
+        ingress:
+          class: nginx`
+
+	if got := buf.String(); got != wantTemplate {
+		t.Errorf("Want\n`%q`\n, but got\n`%q`", wantTemplate, got)
+	}
+}
+
+func Test_HostCertificates(t *testing.T) {
+	tlsTemplate := TLSTemplate{
+		IssuerType: "prod",
+		Hosts:      []string{"system.example.com", "alexellis.example.com"},
+	}
+
+	templateData, err := ioutil.ReadFile("../../templates/k8s/tls/host-certs.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	templateRes := template.Must(template.New("host-certs").Parse(string(templateData)))
+	buf := bytes.Buffer{}
+	if err := templateRes.Execute(&buf, &tlsTemplate); err != nil {
+		t.Fatal(err)
+	}
+
+	got := buf.String()
+	if count := strings.Count(got, "kind: Certificate"); count != 2 {
+		t.Errorf("want 2 certificates, got %d:\n%s", count, got)
+	}
+	for _, want := range []string{
+		"  secretName: alexellis.example.com-cert\n",
+		"    name: letsencrypt-prod\n",
+		"  dnsNames:\n  - 'system.example.com'\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want certificates to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func Test_listTLSTemplates(t *testing.T) {
+	dns01, _ := listTLSTemplates("dns01")
+	if dns01[2] != "wildcard-domain-cert.yml" {
+		t.Errorf("want a wildcard certificate with dns01, got: %v", dns01)
+	}
+
+	http01, _ := listTLSTemplates("http01")
+	if http01[2] != "host-certs.yml" {
+		t.Errorf("want host certificates with http01, got: %v", http01)
+	}
+}
+
 var debugYAML bool
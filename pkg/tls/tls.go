@@ -19,12 +19,30 @@ type TLSTemplate struct {
 	IssuerType  string
 	Region      string
 	AccessKeyID string
+
+	ClientID       string
+	SubscriptionID string
+	TenantID       string
+	ResourceGroup  string
+	HostedZone     string
+
+	Nameserver    string
+	TSIGKeyName   string
+	TSIGAlgorithm string
+
+	ACMEDNSHost           string
+	ServiceConsumerDomain string
+
+	Challenge    string
+	IngressClass string
+	// Hosts get their own certificate with the http01 challenge
+	Hosts []string
 }
 
-// Apply executes the plan
-func Apply(plan types.Plan) error {
+// Apply executes the plan, labelling each object with labels
+func Apply(plan types.Plan, labels map[string]string) error {
 
-	tlsTemplatesList, _ := listTLSTemplates()
+	tlsTemplatesList, _ := listTLSTemplates(plan.TLSConfig.ChallengeType())
 	tlsTemplate := TLSTemplate{
 		RootDomain:  plan.RootDomain,
 		Email:       plan.TLSConfig.Email,
@@ -33,6 +51,23 @@ func Apply(plan types.Plan) error {
 		IssuerType:  plan.TLSConfig.IssuerType,
 		Region:      plan.TLSConfig.Region,
 		AccessKeyID: plan.TLSConfig.AccessKeyID,
+
+		ClientID:       plan.TLSConfig.ClientID,
+		SubscriptionID: plan.TLSConfig.SubscriptionID,
+		TenantID:       plan.TLSConfig.TenantID,
+		ResourceGroup:  plan.TLSConfig.ResourceGroup,
+		HostedZone:     plan.TLSConfig.HostedZone,
+
+		Nameserver:    plan.TLSConfig.Nameserver,
+		TSIGKeyName:   plan.TLSConfig.TSIGKeyName,
+		TSIGAlgorithm: plan.TLSConfig.TSIGAlgorithmName(),
+
+		ACMEDNSHost:           plan.TLSConfig.ACMEDNSHost,
+		ServiceConsumerDomain: plan.TLSConfig.ServiceConsumerDomain,
+
+		Challenge:    plan.TLSConfig.ChallengeType(),
+		IngressClass: plan.TLSConfig.IngressClassName(),
+		Hosts:        plan.TLSHosts(),
 	}
 
 	for _, template := range tlsTemplatesList {
@@ -41,7 +76,7 @@ func Apply(plan types.Plan) error {
 			return tlsTemplateErr
 		}
 
-		if err := applyTemplate(tempFilePath); err != nil {
+		if err := applyTemplate(tempFilePath, labels); err != nil {
 			return err
 		}
 	}
@@ -49,12 +84,18 @@ func Apply(plan types.Plan) error {
 	return nil
 }
 
-func listTLSTemplates() ([]string, error) {
+// listTLSTemplates gives the issuers and certificates for the challenge,
+// http01 cannot issue a wildcard certificate so each host has its own
+func listTLSTemplates(challenge string) ([]string, error) {
+	domainCert := "wildcard-domain-cert.yml"
+	if challenge == types.HTTP01Challenge {
+		domainCert = "host-certs.yml"
+	}
 
 	return []string{
 		"issuer-prod.yml",
 		"issuer-staging.yml",
-		"wildcard-domain-cert.yml",
+		domainCert,
 		"auth-domain-cert.yml",
 	}, nil
 }
@@ -82,7 +123,7 @@ func generateTemplate(fileName string, tlsTemplate TLSTemplate) (string, error)
 	return tempFilePath, nil
 }
 
-func applyTemplate(tempFilePath string) error {
+func applyTemplate(tempFilePath string, labels map[string]string) error {
 
 	execTask := execute.ExecTask{
 		Command:     "kubectl apply -f " + tempFilePath,
@@ -96,5 +137,13 @@ func applyTemplate(tempFilePath string) error {
 	}
 
 	log.Println(execRes.Stdout, execRes.Stderr)
+
+	labelTask := types.BuildLabelFileTask(tempFilePath, labels)
+	labelRes, err := labelTask.Execute()
+	if err != nil {
+		return err
+	}
+
+	log.Println(labelRes.Stdout, labelRes.Stderr)
 	return nil
 }
@@ -0,0 +1,98 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import (
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// AppliedPlanName is the ConfigMap which holds the last-applied plan
+	AppliedPlanName = "ofc-bootstrap-plan"
+	// AppliedPlanNamespace is the namespace of the ConfigMap
+	AppliedPlanNamespace = "openfaas"
+	// AppliedPlanKey is the key of the ConfigMap which holds the plan
+	AppliedPlanKey = "plan.yaml"
+)
+
+// AppliedPlan records the plan used for the last successful apply,
+// along with what was installed and when
+type AppliedPlan struct {
+	Version   string `yaml:"version"`
+	GitCommit string `yaml:"git_commit,omitempty"`
+
+	// Timestamp is when apply completed, in RFC3339 format
+	Timestamp string `yaml:"timestamp"`
+
+	// Sources are the plan files which were merged, see PlanFile.Source
+	Sources []string `yaml:"sources,omitempty"`
+
+	Components []Component `yaml:"components,omitempty"`
+
+	// PlanHash is the plan-hash label of the objects created by apply
+	PlanHash string `yaml:"plan_hash,omitempty"`
+
+	// Plan is the merged plan, with secret values stripped and any values
+	// from the environment which are sensitive redacted, see RedactedPlan
+	Plan Plan `yaml:"plan"`
+}
+
+// Component is a chart or set of functions installed by apply
+type Component struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+	Chart     string `yaml:"chart,omitempty"`
+	Version   string `yaml:"version,omitempty"`
+}
+
+// StripSecretValues removes the values of literals from the plan and its
+// profiles, so that it can be stored. Generate policies and the paths
+// of files are kept.
+func StripSecretValues(plan Plan) Plan {
+	secrets := []KeyValueNamespaceTuple{}
+	for _, secret := range plan.Secrets {
+		literals := []KeyValueTuple{}
+		for _, literal := range secret.Literals {
+			literal.Value = ""
+			literals = append(literals, literal)
+		}
+		secret.Literals = literals
+		secrets = append(secrets, secret)
+	}
+	plan.Secrets = secrets
+
+	if len(plan.Profiles) > 0 {
+		profiles := map[string]Plan{}
+		for name, profile := range plan.Profiles {
+			profiles[name] = StripSecretValues(profile)
+		}
+		plan.Profiles = profiles
+	}
+
+	return plan
+}
+
+// BuildAppliedPlanConfigMap returns a ConfigMap manifest holding the
+// applied plan, for use with kubectl apply
+func BuildAppliedPlanConfigMap(applied AppliedPlan, labels map[string]string) ([]byte, error) {
+	out, err := yaml.Marshal(applied)
+	if err != nil {
+		return nil, err
+	}
+
+	configMap := yaml.MapSlice{
+		{Key: "apiVersion", Value: "v1"},
+		{Key: "kind", Value: "ConfigMap"},
+		{Key: "metadata", Value: yaml.MapSlice{
+			{Key: "name", Value: AppliedPlanName},
+			{Key: "namespace", Value: AppliedPlanNamespace},
+			{Key: "labels", Value: labels},
+		}},
+		{Key: "data", Value: map[string]string{
+			AppliedPlanKey: string(out),
+		}},
+	}
+
+	return yaml.Marshal(configMap)
+}
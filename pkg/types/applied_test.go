@@ -0,0 +1,98 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import (
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func Test_StripSecretValues(t *testing.T) {
+	plan := Plan{
+		Secrets: []KeyValueNamespaceTuple{
+			{
+				Name: "s3-secret-key",
+				Literals: []KeyValueTuple{
+					{Name: "s3-secret-key", Value: "secret", Generate: GeneratePolicy{Length: 40}},
+				},
+				Files: []FileSecret{{Name: "private-key", ValueFrom: "~/key.pem"}},
+			},
+		},
+		Profiles: map[string]Plan{
+			"prod": {Secrets: []KeyValueNamespaceTuple{
+				{Name: "payload-secret", Literals: []KeyValueTuple{{Name: "payload-secret", Value: "prod-secret"}}},
+			}},
+		},
+	}
+
+	got := StripSecretValues(plan)
+
+	literal := got.Secrets[0].Literals[0]
+	if literal.Value != "" {
+		t.Errorf("want literal value stripped, got: %s", literal.Value)
+	}
+	if literal.Generate.Length != 40 {
+		t.Errorf("want generate policy kept, got: %v", literal.Generate)
+	}
+	if got.Secrets[0].Files[0].ValueFrom != "~/key.pem" {
+		t.Errorf("want file path kept, got: %s", got.Secrets[0].Files[0].ValueFrom)
+	}
+	if value := got.Profiles["prod"].Secrets[0].Literals[0].Value; value != "" {
+		t.Errorf("want profile literal value stripped, got: %s", value)
+	}
+
+	if plan.Secrets[0].Literals[0].Value != "secret" {
+		t.Errorf("want the original plan unchanged")
+	}
+}
+
+func Test_BuildAppliedPlanConfigMap(t *testing.T) {
+	applied := AppliedPlan{
+		Version:   "0.10.0",
+		Timestamp: "2020-12-01T10:00:00Z",
+		Sources:   []string{"init.yaml (sha256:abc)"},
+		Components: []Component{
+			{Name: "openfaas", Namespace: "openfaas", Chart: "openfaas-6.1.0", Version: "0.9.0"},
+		},
+		Plan: Plan{RootDomain: "example.com"},
+	}
+
+	out, err := BuildAppliedPlanConfigMap(applied, map[string]string{ManagedByLabel: ManagedByValue})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configMap := struct {
+		Kind     string `yaml:"kind"`
+		Metadata struct {
+			Name      string            `yaml:"name"`
+			Namespace string            `yaml:"namespace"`
+			Labels    map[string]string `yaml:"labels"`
+		} `yaml:"metadata"`
+		Data map[string]string `yaml:"data"`
+	}{}
+	if err := yaml.Unmarshal(out, &configMap); err != nil {
+		t.Fatal(err)
+	}
+
+	if configMap.Kind != "ConfigMap" || configMap.Metadata.Name != AppliedPlanName || configMap.Metadata.Namespace != AppliedPlanNamespace {
+		t.Errorf("unexpected object: %s %s/%s", configMap.Kind, configMap.Metadata.Namespace, configMap.Metadata.Name)
+	}
+	if configMap.Metadata.Labels[ManagedByLabel] != ManagedByValue {
+		t.Errorf("want label %s, got: %v", ManagedByLabel, configMap.Metadata.Labels)
+	}
+
+	got := AppliedPlan{}
+	if err := yaml.Unmarshal([]byte(configMap.Data[AppliedPlanKey]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Plan.RootDomain != "example.com" || got.Version != "0.10.0" || len(got.Components) != 1 {
+		t.Errorf("unexpected applied plan: %+v", got)
+	}
+	if !strings.Contains(configMap.Data[AppliedPlanKey], "timestamp: \"2020-12-01T10:00:00Z\"") {
+		t.Errorf("want timestamp in data, got:\n%s", configMap.Data[AppliedPlanKey])
+	}
+}
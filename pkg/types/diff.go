@@ -0,0 +1,358 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// PlanAdded is a field or list item which is only in the new plan
+	PlanAdded = "added"
+	// PlanRemoved is a field or list item which is only in the old plan
+	PlanRemoved = "removed"
+	// PlanChanged is a field with a different value in each plan
+	PlanChanged = "changed"
+)
+
+// PipelineSteps are the steps run by apply, in order
+var PipelineSteps = []string{
+	"ingress-controller",
+	"secrets",
+	"cert-manager",
+	"openfaas",
+	"ingress",
+	"tls",
+	"gateway_config",
+	"github",
+	"slack",
+	"gitlab",
+	"dashboard_config",
+	"edge-auth",
+	"stack",
+	"of-builder",
+	"aws",
+	"stack-deploy",
+}
+
+// stepRules map a field to the steps which read it. A rule matches the
+// field and any field below it, list items are written without an index.
+var stepRules = []struct {
+	path  string
+	steps []string
+}{
+	{"ingress", []string{"ingress-controller"}},
+	{"secrets", []string{"secrets"}},
+	{"features", []string{"secrets"}},
+	{"scm", []string{"secrets", "gitlab", "dashboard_config", "edge-auth", "stack", "stack-deploy"}},
+	{"enable_oauth", []string{"secrets", "edge-auth", "stack-deploy"}},
+	{"enable_ecr", []string{"secrets", "of-builder", "stack-deploy"}},
+	{"tls", []string{"secrets", "cert-manager", "ingress", "tls", "gateway_config", "dashboard_config", "edge-auth"}},
+	{"tls_config.dns_service", []string{"secrets", "tls"}},
+	{"tls_config.issuer_type", []string{"ingress", "tls"}},
+	{"tls_config.challenge", []string{"secrets", "ingress", "tls"}},
+	{"tls_config.subdomains", []string{"ingress", "tls"}},
+	{"tls_config.mode", []string{"secrets", "cert-manager", "ingress", "tls"}},
+	{"tls_config.cert_file", []string{"secrets"}},
+	{"tls_config.key_file", []string{"secrets"}},
+	{"tls_config.ca_file", []string{"secrets"}},
+	{"tls_config", []string{"tls"}},
+	{"scale_to_zero", []string{"openfaas"}},
+	{"ingress_operator", []string{"openfaas"}},
+	{"openfaas_operator", []string{"openfaas"}},
+	{"root_domain", []string{"ingress", "tls", "gateway_config", "dashboard_config", "edge-auth"}},
+	{"registry", []string{"gateway_config"}},
+	{"customers_url", []string{"gateway_config", "edge-auth"}},
+	{"customers_secret", []string{"gateway_config", "edge-auth", "stack"}},
+	{"s3", []string{"gateway_config"}},
+	{"deployment", []string{"gateway_config"}},
+	{"enable_dockerfile_lang", []string{"gateway_config"}},
+	{"build_branch", []string{"gateway_config"}},
+	{"github.public_link", []string{"dashboard_config"}},
+	{"github", []string{"github"}},
+	{"slack", []string{"slack"}},
+	{"gitlab", []string{"gitlab", "dashboard_config"}},
+	{"oauth", []string{"edge-auth"}},
+	{"ecr_config", []string{"aws"}},
+	{"openfaas_cloud_version", []string{"stack-deploy"}},
+	{"network_policies", []string{"stack-deploy"}},
+}
+
+// templateSteps generate the templates deployed by stack-deploy
+var templateSteps = map[string]bool{
+	"gateway_config":   true,
+	"github":           true,
+	"slack":            true,
+	"gitlab":           true,
+	"dashboard_config": true,
+	"edge-auth":        true,
+	"stack":            true,
+	"of-builder":       true,
+	"aws":              true,
+}
+
+// PlanChange is a difference between two plans
+type PlanChange struct {
+	// Path of the field, with secrets and other named items
+	// written as secrets[name]
+	Path string
+	Kind string
+	Old  string
+	New  string
+}
+
+func (c PlanChange) String() string {
+	switch c.Kind {
+	case PlanAdded:
+		return fmt.Sprintf("+ %s: %s", c.Path, c.New)
+	case PlanRemoved:
+		return fmt.Sprintf("- %s: %s", c.Path, c.Old)
+	}
+	if c.Old == c.New {
+		// Both values are redacted
+		return fmt.Sprintf("~ %s: %s (changed)", c.Path, c.New)
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", c.Path, c.Old, c.New)
+}
+
+// RedactPlanChanges masks any of the sensitive values in the old and new
+// values of the changes, such as secrets interpolated from the environment
+func RedactPlanChanges(changes []PlanChange, sensitive []string) []PlanChange {
+	redacted := []PlanChange{}
+	for _, change := range changes {
+		change.Old = redactValues(change.Old, sensitive)
+		change.New = redactValues(change.New, sensitive)
+		redacted = append(redacted, change)
+	}
+	return redacted
+}
+
+func redactValues(value string, sensitive []string) string {
+	for _, s := range sensitive {
+		if len(s) > 0 {
+			value = strings.Replace(value, s, RedactedValue, -1)
+		}
+	}
+	return value
+}
+
+// DiffPlans compares two plans field by field. Items of lists of maps
+// with a name, such as secrets, are matched by name, and other lists
+// are compared as sets. The values of secret literals are redacted.
+func DiffPlans(oldPlan, newPlan Plan) ([]PlanChange, error) {
+	oldDoc, err := planDocument(oldPlan)
+	if err != nil {
+		return nil, err
+	}
+	newDoc, err := planDocument(newPlan)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffValues("", oldDoc, newDoc), nil
+}
+
+// AffectedSteps lists the pipeline steps which read the changed fields,
+// in the order in which they are run
+func AffectedSteps(changes []PlanChange) []string {
+	affected := map[string]bool{}
+	for _, change := range changes {
+		path := genericPath(change.Path)
+		for _, rule := range stepRules {
+			if path == rule.path || strings.HasPrefix(path, rule.path+".") {
+				for _, step := range rule.steps {
+					affected[step] = true
+				}
+				break
+			}
+		}
+	}
+
+	for step := range affected {
+		if templateSteps[step] {
+			affected["stack-deploy"] = true
+		}
+	}
+
+	steps := []string{}
+	for _, step := range PipelineSteps {
+		if affected[step] {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+func planDocument(plan Plan) (yaml.MapSlice, error) {
+	out, err := yaml.Marshal(plan)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := yaml.MapSlice{}
+	if err := yaml.Unmarshal(out, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func diffValues(path string, oldValue, newValue interface{}) []PlanChange {
+	oldMap, oldIsMap := oldValue.(yaml.MapSlice)
+	newMap, newIsMap := newValue.(yaml.MapSlice)
+	if oldIsMap && newIsMap {
+		return diffMaps(path, oldMap, newMap)
+	}
+
+	oldList, oldIsList := oldValue.([]interface{})
+	newList, newIsList := newValue.([]interface{})
+	if oldIsList && newIsList {
+		if namedList(oldList) && namedList(newList) {
+			return diffNamedLists(path, oldList, newList)
+		}
+		return diffLists(path, oldList, newList)
+	}
+
+	if reflect.DeepEqual(oldValue, newValue) {
+		return nil
+	}
+
+	switch {
+	case oldValue == nil:
+		return []PlanChange{{Path: path, Kind: PlanAdded, New: diffValue(path, newValue)}}
+	case newValue == nil:
+		return []PlanChange{{Path: path, Kind: PlanRemoved, Old: diffValue(path, oldValue)}}
+	}
+	return []PlanChange{{Path: path, Kind: PlanChanged, Old: diffValue(path, oldValue), New: diffValue(path, newValue)}}
+}
+
+func diffMaps(path string, oldMap, newMap yaml.MapSlice) []PlanChange {
+	changes := []PlanChange{}
+	for _, item := range oldMap {
+		key := fmt.Sprintf("%v", item.Key)
+		newValue, _ := lookupKey(newMap, key)
+		changes = append(changes, diffValues(joinPath(path, key), item.Value, newValue)...)
+	}
+
+	for _, item := range newMap {
+		key := fmt.Sprintf("%v", item.Key)
+		if _, found := lookupKey(oldMap, key); !found {
+			changes = append(changes, diffValues(joinPath(path, key), nil, item.Value)...)
+		}
+	}
+	return changes
+}
+
+func diffNamedLists(path string, oldList, newList []interface{}) []PlanChange {
+	changes := []PlanChange{}
+	for _, item := range oldList {
+		name, _ := lookupKey(item.(yaml.MapSlice), "name")
+		newItem := findNamed(newList, name)
+		changes = append(changes, diffValues(fmt.Sprintf("%s[%v]", path, name), item, newItem)...)
+	}
+
+	for _, item := range newList {
+		name, _ := lookupKey(item.(yaml.MapSlice), "name")
+		if findNamed(oldList, name) == nil {
+			changes = append(changes, diffValues(fmt.Sprintf("%s[%v]", path, name), nil, item)...)
+		}
+	}
+	return changes
+}
+
+func diffLists(path string, oldList, newList []interface{}) []PlanChange {
+	changes := []PlanChange{}
+	for _, item := range oldList {
+		if !containsItem(newList, item) {
+			changes = append(changes, PlanChange{Path: path, Kind: PlanRemoved, Old: diffValue(path, item)})
+		}
+	}
+	for _, item := range newList {
+		if !containsItem(oldList, item) {
+			changes = append(changes, PlanChange{Path: path, Kind: PlanAdded, New: diffValue(path, item)})
+		}
+	}
+	return changes
+}
+
+// namedList is true when every item is a map with a name
+func namedList(list []interface{}) bool {
+	for _, item := range list {
+		itemMap, ok := item.(yaml.MapSlice)
+		if !ok {
+			return false
+		}
+		if _, found := lookupKey(itemMap, "name"); !found {
+			return false
+		}
+	}
+	return true
+}
+
+func findNamed(list []interface{}, name interface{}) interface{} {
+	for _, item := range list {
+		if itemName, _ := lookupKey(item.(yaml.MapSlice), "name"); reflect.DeepEqual(itemName, name) {
+			return item
+		}
+	}
+	return nil
+}
+
+// diffValue formats a value on a single line, the value of a
+// secret literal, or any map which holds one, is redacted
+func diffValue(path string, value interface{}) string {
+	if genericPath(path) == "secrets.literals.value" {
+		return RedactedValue
+	}
+
+	if strings.HasPrefix(genericPath(path), "secrets") {
+		value = redactLiteralValues(value)
+	}
+	return flowYAML(value)
+}
+
+// redactLiteralValues replaces the value of any literal within a secret
+// or a list of literals
+func redactLiteralValues(value interface{}) interface{} {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		out := yaml.MapSlice{}
+		for _, item := range v {
+			if item.Key == "value" {
+				out = append(out, yaml.MapItem{Key: item.Key, Value: RedactedValue})
+				continue
+			}
+			out = append(out, yaml.MapItem{Key: item.Key, Value: redactLiteralValues(item.Value)})
+		}
+		return out
+	case []interface{}:
+		out := []interface{}{}
+		for _, item := range v {
+			out = append(out, redactLiteralValues(item))
+		}
+		return out
+	}
+	return value
+}
+
+func flowYAML(value interface{}) string {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		parts := []string{}
+		for _, item := range v {
+			parts = append(parts, fmt.Sprintf("%v: %s", item.Key, flowYAML(item.Value)))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case []interface{}:
+		parts := []string{}
+		for _, item := range v {
+			parts = append(parts, flowYAML(item))
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	}
+	return scalarYAML(value)
+}
@@ -0,0 +1,137 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_DiffPlans_NoChanges(t *testing.T) {
+	plan := Plan{RootDomain: "example.com", Features: []string{"auth"}}
+
+	changes, err := DiffPlans(plan, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("want no changes, got: %v", changes)
+	}
+}
+
+func Test_DiffPlans_FieldsAndLists(t *testing.T) {
+	oldPlan := Plan{
+		RootDomain: "example.com",
+		TLSConfig:  TLSConfig{Email: "admin@example.com", IssuerType: "staging"},
+		Deployment: Deployment{CustomTemplate: []string{"https://github.com/openfaas/templates"}},
+	}
+	newPlan := Plan{
+		RootDomain:  "example.com",
+		TLSConfig:   TLSConfig{Email: "ops@example.com", IssuerType: "staging"},
+		Deployment:  Deployment{CustomTemplate: []string{"https://github.com/openfaas/templates", "https://github.com/example/templates"}},
+		ScaleToZero: true,
+	}
+
+	changes, err := DiffPlans(oldPlan, newPlan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, change := range changes {
+		got = append(got, change.String())
+	}
+
+	want := []string{
+		"~ tls_config.email: admin@example.com -> ops@example.com",
+		"+ deployment.custom_templates: https://github.com/example/templates",
+		"+ scale_to_zero: true",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func Test_DiffPlans_SecretsByNameAreRedacted(t *testing.T) {
+	oldPlan := Plan{Secrets: []KeyValueNamespaceTuple{
+		{Name: "payload-secret", Namespace: "openfaas", Literals: []KeyValueTuple{{Name: "payload-secret", Value: "old"}}},
+		{Name: "s3-secret-key", Namespace: "openfaas"},
+	}}
+	newPlan := Plan{Secrets: []KeyValueNamespaceTuple{
+		{Name: "s3-secret-key", Namespace: "openfaas-fn"},
+		{Name: "payload-secret", Namespace: "openfaas", Literals: []KeyValueTuple{{Name: "payload-secret", Value: "new"}}},
+		{Name: "slack", Namespace: "openfaas", Literals: []KeyValueTuple{{Name: "url", Value: "https://hooks.slack.com/x"}}},
+	}}
+
+	changes, err := DiffPlans(oldPlan, newPlan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, change := range changes {
+		got = append(got, change.String())
+	}
+
+	want := []string{
+		"~ secrets[payload-secret].literals[payload-secret].value: <redacted> (changed)",
+		"~ secrets[s3-secret-key].namespace: openfaas -> openfaas-fn",
+		"+ secrets[slack]: {name: slack, literals: [{name: url, value: <redacted>}], namespace: openfaas}",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func Test_RedactPlanChanges(t *testing.T) {
+	oldPlan := Plan{RootDomain: "ofc.example.com", CustomersURL: "https://example.com/customers?token=t0ken"}
+	newPlan := Plan{RootDomain: "ofc.example.org", CustomersURL: "https://example.com/customers?token=n3w"}
+
+	changes, err := DiffPlans(oldPlan, newPlan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, change := range RedactPlanChanges(changes, []string{"t0ken", "n3w"}) {
+		got = append(got, change.String())
+	}
+
+	want := []string{
+		"~ root_domain: ofc.example.com -> ofc.example.org",
+		"~ customers_url: https://example.com/customers?token=<redacted> (changed)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func Test_AffectedSteps(t *testing.T) {
+	cases := []struct {
+		name  string
+		paths []string
+		want  string
+	}{
+		{name: "tls_config", paths: []string{"tls_config.email"}, want: "tls"},
+		{name: "issuer_type", paths: []string{"tls_config.issuer_type"}, want: "ingress,tls"},
+		{name: "custom_templates", paths: []string{"deployment.custom_templates"}, want: "gateway_config,stack-deploy"},
+		{name: "secret", paths: []string{"secrets[payload-secret].literals[payload-secret].value"}, want: "secrets"},
+		{name: "scale_to_zero and slack", paths: []string{"slack.url", "scale_to_zero"}, want: "openfaas,slack,stack-deploy"},
+		{name: "unknown", paths: []string{"profiles.prod.root_domain"}, want: ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			changes := []PlanChange{}
+			for _, path := range c.paths {
+				changes = append(changes, PlanChange{Path: path, Kind: PlanChanged})
+			}
+
+			got := strings.Join(AffectedSteps(changes), ",")
+			if got != c.want {
+				t.Errorf("want: %s, got: %s", c.want, got)
+			}
+		})
+	}
+}
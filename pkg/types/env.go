@@ -0,0 +1,195 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// RedactedValue replaces sensitive values in output
+const RedactedValue = "<redacted>"
+
+// envPattern matches ${VAR} and ${VAR:-default}, $${VAR} is
+// an escaped reference which is written out as ${VAR}
+var envPattern = regexp.MustCompile(`\$(\$)?\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// InterpolateEnv replaces ${VAR} and ${VAR:-default} in a plan file with
+// values from lookup, the default is used when VAR is unset or empty.
+// Undefined variables are replaced with an empty string, unless strict is
+// set, then an error is returned naming each of them. References in YAML
+// comments are left as they are. The values which were substituted under
+// a secrets key are returned, so that they can be kept out of logs.
+func InterpolateEnv(data []byte, lookup func(string) (string, bool), strict bool) ([]byte, []string, error) {
+	values := []string{}
+	undefined := []string{}
+
+	lines := bytes.Split(data, []byte("\n"))
+	inSecrets := secretLines(lines)
+	for i, line := range lines {
+		comment := yamlCommentIndex(line)
+		replaced := envPattern.ReplaceAllFunc(line[:comment], func(match []byte) []byte {
+			parts := envPattern.FindSubmatch(match)
+			escaped, name, defaultValue := len(parts[1]) > 0, string(parts[2]), parts[3]
+
+			if escaped {
+				return match[1:]
+			}
+
+			value, ok := lookup(name)
+			if len(value) == 0 && defaultValue != nil && bytes.Contains(match, []byte(":-")) {
+				value, ok = string(defaultValue), true
+			}
+
+			if !ok {
+				undefined = append(undefined, fmt.Sprintf("%s (line %d)", name, i+1))
+				return []byte{}
+			}
+
+			if len(value) > 0 && inSecrets[i] {
+				values = append(values, value)
+			}
+			return []byte(value)
+		})
+		lines[i] = append(replaced, line[comment:]...)
+	}
+
+	if strict && len(undefined) > 0 {
+		return nil, nil, fmt.Errorf("undefined environment variables: %s", strings.Join(undefined, ", "))
+	}
+
+	return bytes.Join(lines, []byte("\n")), values, nil
+}
+
+// yamlCommentIndex returns the index of the # which starts a comment on
+// a line of YAML, or the length of the line when there is none. A # only
+// starts a comment at the start of a line or after whitespace, outside of
+// quotes and ${VAR} references.
+func yamlCommentIndex(line []byte) int {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '$' && i+1 < len(line) && line[i+1] == '{':
+			if end := bytes.IndexByte(line[i:], '}'); end > -1 {
+				i += end
+			}
+		case c == '"' || c == '\'':
+			// a quote only opens a scalar at its start, not in it's
+			if i == 0 || bytes.IndexByte([]byte(" \t:-[{,"), line[i-1]) > -1 {
+				quote = c
+			}
+		case c == '#':
+			if i == 0 || line[i-1] == ' ' || line[i-1] == '\t' {
+				return i
+			}
+		}
+	}
+	return len(line)
+}
+
+// yamlKeyPattern matches the key of a line of block-style YAML, along with
+// its indentation and any list marker
+var yamlKeyPattern = regexp.MustCompile(`^(\s*(?:-\s+)*)([A-Za-z0-9_.-]+)\s*:`)
+
+// secretLines marks each line which is below a secrets key, at any depth,
+// such as the literals and files of the secrets of a plan or a profile
+func secretLines(lines [][]byte) []bool {
+	type key struct {
+		indent int
+		name   string
+	}
+
+	marked := make([]bool, len(lines))
+	stack := []key{}
+	for i, line := range lines {
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) == 0 || trimmed[0] == '#' {
+			continue
+		}
+
+		// a list item may be at the same indent as its parent key
+		indent := len(line) - len(bytes.TrimLeft(line, " "))
+		listItem := trimmed[0] == '-'
+		for len(stack) > 0 {
+			top := stack[len(stack)-1].indent
+			if top < indent || (listItem && top == indent) {
+				break
+			}
+			stack = stack[:len(stack)-1]
+		}
+
+		if match := yamlKeyPattern.FindSubmatch(line); match != nil {
+			stack = append(stack, key{indent: len(match[1]), name: string(match[2])})
+		}
+
+		for _, k := range stack {
+			if k.name == "secrets" {
+				marked[i] = true
+			}
+		}
+	}
+	return marked
+}
+
+// RedactedPlan returns a copy of the plan where the sensitive values are
+// masked in every field, including those of its profiles, since a value
+// from the environment may be used outside of the secrets too
+func RedactedPlan(plan Plan, sensitive []string) Plan {
+	if len(sensitive) == 0 {
+		return plan
+	}
+
+	// A Plan is written out and read back without loss, the redacted
+	// values are only ever strings in string fields
+	out, _ := yaml.Marshal(plan)
+	doc := yaml.MapSlice{}
+	yaml.Unmarshal(out, &doc)
+	out, _ = yaml.Marshal(redactDocument(doc, sensitive))
+
+	redacted := Plan{}
+	yaml.Unmarshal(out, &redacted)
+	return redacted
+}
+
+// redactDocument masks the sensitive values in the strings of a YAML
+// document, other scalars such as booleans and numbers are kept
+func redactDocument(value interface{}, sensitive []string) interface{} {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		out := yaml.MapSlice{}
+		for _, item := range v {
+			out = append(out, yaml.MapItem{Key: item.Key, Value: redactDocument(item.Value, sensitive)})
+		}
+		return out
+	case []interface{}:
+		out := []interface{}{}
+		for _, item := range v {
+			out = append(out, redactDocument(item, sensitive))
+		}
+		return out
+	case string:
+		return redactValues(v, sensitive)
+	}
+	return value
+}
+
+func containsAny(value string, sensitive []string) bool {
+	for _, s := range sensitive {
+		if len(s) > 0 && strings.Contains(value, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,229 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+func lookupFrom(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+func Test_InterpolateEnv(t *testing.T) {
+	env := map[string]string{
+		"ROOT_DOMAIN": "ofc.example.com",
+		"EMPTY":       "",
+	}
+
+	tests := []struct {
+		title string
+		input string
+		want  string
+	}{
+		{
+			title: "variable is replaced",
+			input: "root_domain: ${ROOT_DOMAIN}",
+			want:  "root_domain: ofc.example.com",
+		},
+		{
+			title: "default is used when unset",
+			input: "registry: ${REGISTRY:-docker.io/ofctest/}",
+			want:  "registry: docker.io/ofctest/",
+		},
+		{
+			title: "default is used when empty",
+			input: "build_branch: ${EMPTY:-master}",
+			want:  "build_branch: master",
+		},
+		{
+			title: "undefined variable is empty",
+			input: "app_id: \"${APP_ID}\"",
+			want:  "app_id: \"\"",
+		},
+		{
+			title: "escaped reference is kept",
+			input: "value: $${ROOT_DOMAIN}",
+			want:  "value: ${ROOT_DOMAIN}",
+		},
+		{
+			title: "merge markers are not changed",
+			input: "features: [$replace, one]",
+			want:  "features: [$replace, one]",
+		},
+		{
+			title: "comment is not changed",
+			input: "root_domain: ${ROOT_DOMAIN} # was ${ROOT_DOMAIN}",
+			want:  "root_domain: ofc.example.com # was ${ROOT_DOMAIN}",
+		},
+		{
+			title: "hash in a quoted value is not a comment",
+			input: "value: \"a #${ROOT_DOMAIN}\" # ${ROOT_DOMAIN}",
+			want:  "value: \"a #ofc.example.com\" # ${ROOT_DOMAIN}",
+		},
+		{
+			title: "hash in a default is not a comment",
+			input: "value: ${UNSET:-a #b}",
+			want:  "value: a #b",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			got, _, err := InterpolateEnv([]byte(test.input), lookupFrom(env), false)
+			if err != nil {
+				t.Fatalf("want no error, got: %s", err.Error())
+			}
+			if string(got) != test.want {
+				t.Errorf("want: %q, got: %q", test.want, string(got))
+			}
+		})
+	}
+}
+
+func Test_InterpolateEnv_StrictFailsOnUndefined(t *testing.T) {
+	input := "root_domain: ${ROOT_DOMAIN}\nregistry: ${REGISTRY:-docker.io/ofctest/}\ns3:\n  s3_bucket: ${BUCKET}\n"
+
+	_, _, err := InterpolateEnv([]byte(input), lookupFrom(map[string]string{}), true)
+	if err == nil {
+		t.Fatalf("want an error for undefined variables")
+	}
+
+	want := "ROOT_DOMAIN (line 1), BUCKET (line 4)"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("want error containing: %q, got: %s", want, err.Error())
+	}
+}
+
+func Test_InterpolateEnv_StrictIgnoresComments(t *testing.T) {
+	input := "# ${UNDEFINED} is documented here\nroot_domain: ${ROOT_DOMAIN} # or ${UNDEFINED}\n"
+
+	got, _, err := InterpolateEnv([]byte(input), lookupFrom(map[string]string{"ROOT_DOMAIN": "ofc.example.com"}), true)
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+
+	want := "# ${UNDEFINED} is documented here\nroot_domain: ofc.example.com # or ${UNDEFINED}\n"
+	if string(got) != want {
+		t.Errorf("want: %q, got: %q", want, string(got))
+	}
+}
+
+func Test_InterpolateEnv_OnlySecretValuesAreSensitive(t *testing.T) {
+	input := []byte(`root_domain: ${ROOT_DOMAIN}
+tls: ${TLS}
+secrets:
+- name: basic-auth
+  literals:
+  - name: basic-auth-password
+    value: ${ADMIN_PASSWORD}
+  - name: basic-auth-domain
+    value: "admin.${ROOT_DOMAIN}"
+enable_oauth: ${TLS}
+profiles:
+  prod:
+    registry: ${REGISTRY}
+    secrets:
+      - name: of-client-secret
+        literals:
+          - name: of-client-secret
+            value: ${CLIENT_SECRET}
+`)
+	env := map[string]string{
+		"ROOT_DOMAIN":    "ofc.example.com",
+		"TLS":            "true",
+		"ADMIN_PASSWORD": "pa55",
+		"REGISTRY":       "ghcr.io/ofc/",
+		"CLIENT_SECRET":  "s3cr3t",
+	}
+
+	_, sensitive, err := InterpolateEnv(input, lookupFrom(env), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "pa55,ofc.example.com,s3cr3t"
+	if got := strings.Join(sensitive, ","); got != want {
+		t.Errorf("want sensitive values: %s, got: %s", want, got)
+	}
+}
+
+func Test_RedactedPlan_MasksInterpolatedSecrets(t *testing.T) {
+	input := []byte(`secrets:
+- name: of-client-secret
+  literals:
+  - name: of-client-secret
+    value: ${CLIENT_SECRET}
+- name: basic-auth
+  literals:
+  - name: basic-auth-user
+    value: admin
+`)
+
+	out, sensitive, err := InterpolateEnv(input, lookupFrom(map[string]string{"CLIENT_SECRET": "s3cr3t"}), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := MergePlanFiles([]PlanFile{{Name: "init.yaml", Data: out, Sensitive: sensitive}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redacted := RedactedPlan(*plan, sensitive)
+	if got := redacted.Secrets[0].Literals[0].Value; got != RedactedValue {
+		t.Errorf("want interpolated secret redacted, got: %q", got)
+	}
+	if got := redacted.Secrets[1].Literals[0].Value; got != "admin" {
+		t.Errorf("want other values kept, got: %q", got)
+	}
+	if plan.Secrets[0].Literals[0].Value != "s3cr3t" {
+		t.Errorf("want the original plan to be unchanged")
+	}
+
+	explained, err := ExplainPlanFiles([]PlanFile{{Name: "init.yaml", Data: out, Sensitive: sensitive}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(explained, "s3cr3t") {
+		t.Errorf("want interpolated secret redacted from explain output, got:\n%s", explained)
+	}
+}
+
+func Test_RedactedPlan_MasksValuesOutsideSecrets(t *testing.T) {
+	plan := Plan{
+		RootDomain:   "ofc.example.com",
+		CustomersURL: "https://example.com/customers?token=s3cr3t",
+		TLS:          true,
+		Secrets: []KeyValueNamespaceTuple{{
+			Name:     "customers-token",
+			Literals: []KeyValueTuple{{Name: "token", Value: "s3cr3t"}},
+		}},
+		Profiles: map[string]Plan{
+			"prod": {CustomersURL: "https://example.com/prod?token=s3cr3t"},
+		},
+	}
+
+	redacted := RedactedPlan(plan, []string{"s3cr3t"})
+
+	if got := redacted.CustomersURL; got != "https://example.com/customers?token="+RedactedValue {
+		t.Errorf("want customers_url redacted, got: %s", got)
+	}
+	if got := redacted.Profiles["prod"].CustomersURL; got != "https://example.com/prod?token="+RedactedValue {
+		t.Errorf("want the profile redacted, got: %s", got)
+	}
+	if got := redacted.Secrets[0].Literals[0].Value; got != RedactedValue {
+		t.Errorf("want the literal redacted, got: %s", got)
+	}
+	if redacted.RootDomain != "ofc.example.com" || !redacted.TLS {
+		t.Errorf("want other values kept, got: %s %t", redacted.RootDomain, redacted.TLS)
+	}
+	if plan.CustomersURL != "https://example.com/customers?token=s3cr3t" {
+		t.Errorf("want the original plan to be unchanged")
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import (
+	"fmt"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Feature enables a set of secrets, through their filters, and
+// declares what else it needs from the plan
+type Feature struct {
+	Name        string
+	Description string
+
+	// EnabledBy is the plan field and value which turn on the feature,
+	// it is empty for the default feature, which is always enabled
+	EnabledBy FeatureSwitch
+
+	// Secrets must be enabled by one of the features of the plan
+	// and have at least the given keys
+	Secrets []RequiredSecret
+
+	// Fields of the plan which must be set, written as yaml paths
+	// i.e. tls_config.email
+	Fields []string
+
+	// Conflicts are features which cannot be enabled at the same time
+	Conflicts []string
+}
+
+// FeatureSwitch is a plan field and the value which enables a feature
+type FeatureSwitch struct {
+	Field string
+	Value string
+}
+
+func (s FeatureSwitch) String() string {
+	if len(s.Field) == 0 {
+		return "always"
+	}
+	return s.Field + ": " + s.Value
+}
+
+// RequiredSecret is a secret and the keys it must have
+type RequiredSecret struct {
+	Name string
+	Keys []string
+}
+
+func (s RequiredSecret) String() string {
+	return s.Name + "[" + strings.Join(s.Keys, ",") + "]"
+}
+
+var dnsFeatures = []string{DODNS, GCPDNS, Route53DNS, CloudflareDNS, AzureDNS, RFC2136DNS, ACMEDNS, AkamaiDNS}
+
+// Features is the registry of features, in the order in which they
+// are added to a plan
+var Features = []Feature{
+	{
+		Name:        DefaultFeature,
+		Description: "Core components, object storage and the registry",
+		Secrets: []RequiredSecret{
+			{Name: "s3-secret-key", Keys: []string{"s3-secret-key"}},
+			{Name: "s3-access-key", Keys: []string{"s3-access-key"}},
+			{Name: "basic-auth", Keys: []string{"basic-auth-user", "basic-auth-password"}},
+			{Name: "payload-secret", Keys: []string{"payload-secret"}},
+			{Name: "registry-secret", Keys: []string{"config.json"}},
+			{Name: "registry-pull-secret", Keys: []string{".dockerconfigjson"}},
+		},
+		Fields: []string{"root_domain", "registry"},
+	},
+	{
+		Name:        ECRFeature,
+		Description: "Push images to AWS ECR",
+		EnabledBy:   FeatureSwitch{Field: "enable_ecr", Value: "true"},
+		Secrets: []RequiredSecret{
+			{Name: "aws-ecr-credentials", Keys: []string{"credentials"}},
+			{Name: "aws-ecr-createrepo-credentials", Keys: []string{"credentials"}},
+		},
+		Fields: []string{"ecr_config.ecr_region"},
+	},
+	{
+		Name:        GitHubFeature,
+		Description: "Build repositories from GitHub with a GitHub App",
+		EnabledBy:   FeatureSwitch{Field: "scm", Value: GitHubSCM},
+		Secrets: []RequiredSecret{
+			{Name: "github-webhook-secret", Keys: []string{"github-webhook-secret"}},
+			{Name: "private-key", Keys: []string{"private-key"}},
+		},
+		Fields:    []string{"github.app_id"},
+		Conflicts: []string{GitLabFeature},
+	},
+	{
+		Name:        GitLabFeature,
+		Description: "Build repositories from a self-hosted GitLab",
+		EnabledBy:   FeatureSwitch{Field: "scm", Value: GitLabSCM},
+		Secrets: []RequiredSecret{
+			{Name: "gitlab-webhook-secret", Keys: []string{"gitlab-webhook-secret"}},
+			{Name: "gitlab-api-token", Keys: []string{"gitlab-api-token"}},
+		},
+		Fields:    []string{"gitlab.gitlab_instance"},
+		Conflicts: []string{GitHubFeature},
+	},
+	{
+		Name:        DODNS,
+		Description: "DNS01 challenges with DigitalOcean",
+		EnabledBy:   FeatureSwitch{Field: "tls_config.dns_service", Value: DigitalOcean},
+		Secrets: []RequiredSecret{
+			{Name: "digitalocean-dns", Keys: []string{"access-token"}},
+		},
+		Fields:    []string{"tls_config.email"},
+		Conflicts: otherFeatures(dnsFeatures, DODNS),
+	},
+	{
+		Name:        GCPDNS,
+		Description: "DNS01 challenges with Google Cloud DNS",
+		EnabledBy:   FeatureSwitch{Field: "tls_config.dns_service", Value: CloudDNS},
+		Secrets: []RequiredSecret{
+			{Name: "clouddns-service-account", Keys: []string{"service-account.json"}},
+		},
+		Fields:    []string{"tls_config.email", "tls_config.project_id"},
+		Conflicts: otherFeatures(dnsFeatures, GCPDNS),
+	},
+	{
+		Name:        Route53DNS,
+		Description: "DNS01 challenges with AWS Route 53",
+		EnabledBy:   FeatureSwitch{Field: "tls_config.dns_service", Value: Route53},
+		Secrets: []RequiredSecret{
+			{Name: "route53-credentials-secret", Keys: []string{"secret-access-key"}},
+		},
+		Fields:    []string{"tls_config.email", "tls_config.region"},
+		Conflicts: otherFeatures(dnsFeatures, Route53DNS),
+	},
+	{
+		Name:        CloudflareDNS,
+		Description: "DNS01 challenges with Cloudflare",
+		EnabledBy:   FeatureSwitch{Field: "tls_config.dns_service", Value: Cloudflare},
+		Secrets: []RequiredSecret{
+			{Name: "cloudflare-api-key-secret", Keys: []string{"api-key"}},
+		},
+		Fields:    []string{"tls_config.email"},
+		Conflicts: otherFeatures(dnsFeatures, CloudflareDNS),
+	},
+	{
+		Name:        AzureDNS,
+		Description: "DNS01 challenges with Azure DNS",
+		EnabledBy:   FeatureSwitch{Field: "tls_config.dns_service", Value: Azure},
+		Secrets: []RequiredSecret{
+			{Name: "azuredns-config", Keys: []string{"client-secret"}},
+		},
+		Fields: []string{
+			"tls_config.email",
+			"tls_config.client_id",
+			"tls_config.subscription_id",
+			"tls_config.tenant_id",
+			"tls_config.resource_group",
+			"tls_config.hosted_zone",
+		},
+		Conflicts: otherFeatures(dnsFeatures, AzureDNS),
+	},
+	{
+		Name:        RFC2136DNS,
+		Description: "DNS01 challenges with an RFC2136 nameserver, i.e. BIND with TSIG",
+		EnabledBy:   FeatureSwitch{Field: "tls_config.dns_service", Value: RFC2136},
+		Secrets: []RequiredSecret{
+			{Name: "rfc2136-tsig-secret", Keys: []string{"tsig-secret-key"}},
+		},
+		Fields:    []string{"tls_config.email", "tls_config.nameserver", "tls_config.tsig_key_name"},
+		Conflicts: otherFeatures(dnsFeatures, RFC2136DNS),
+	},
+	{
+		Name:        ACMEDNS,
+		Description: "DNS01 challenges with an acme-dns server",
+		EnabledBy:   FeatureSwitch{Field: "tls_config.dns_service", Value: ACMEDNSProvider},
+		Secrets: []RequiredSecret{
+			{Name: "acme-dns", Keys: []string{"acmedns.json"}},
+		},
+		Fields:    []string{"tls_config.email", "tls_config.acme_dns_host"},
+		Conflicts: otherFeatures(dnsFeatures, ACMEDNS),
+	},
+	{
+		Name:        AkamaiDNS,
+		Description: "DNS01 challenges with Akamai Edge DNS",
+		EnabledBy:   FeatureSwitch{Field: "tls_config.dns_service", Value: Akamai},
+		Secrets: []RequiredSecret{
+			{Name: "akamai-dns", Keys: []string{"client-token", "client-secret", "access-token"}},
+		},
+		Fields:    []string{"tls_config.email", "tls_config.service_consumer_domain"},
+		Conflicts: otherFeatures(dnsFeatures, AkamaiDNS),
+	},
+	{
+		Name:        HTTP01Feature,
+		Description: "HTTP01 challenges through the ingress controller, with a certificate per host",
+		EnabledBy:   FeatureSwitch{Field: "tls_config.challenge", Value: HTTP01Challenge},
+		Fields:      []string{"tls_config.email"},
+	},
+	{
+		Name:        CustomTLSFeature,
+		Description: "TLS with your own wildcard certificate, without cert-manager",
+		EnabledBy:   FeatureSwitch{Field: "tls_config.mode", Value: CustomTLSMode},
+		Fields:      []string{"tls_config.cert_file", "tls_config.key_file"},
+		Conflicts:   append([]string{HTTP01Feature}, dnsFeatures...),
+	},
+	{
+		Name:        Auth,
+		Description: "Log in to the dashboard with OAuth",
+		EnabledBy:   FeatureSwitch{Field: "enable_oauth", Value: "true"},
+		Secrets: []RequiredSecret{
+			{Name: "of-client-secret", Keys: []string{"of-client-secret"}},
+			{Name: "jwt-private-key", Keys: []string{"key"}},
+			{Name: "jwt-public-key", Keys: []string{"key.pub"}},
+		},
+		Fields: []string{"oauth.client_id"},
+	},
+}
+
+// FeatureFor finds the feature enabled by a plan field and value
+func FeatureFor(field, value string) (Feature, bool) {
+	for _, feature := range Features {
+		if feature.EnabledBy.Field == field && feature.EnabledBy.Value == value {
+			return feature, true
+		}
+	}
+	return Feature{}, false
+}
+
+// GetFeature finds a feature by name
+func GetFeature(name string) (Feature, bool) {
+	for _, feature := range Features {
+		if feature.Name == name {
+			return feature, true
+		}
+	}
+	return Feature{}, false
+}
+
+// ValidateFeatures checks the plan against the registry for each of its
+// features: required secrets must be enabled with their keys, required
+// fields must be set and no two conflicting features may be enabled.
+// Features must already have been added to the plan.
+func ValidateFeatures(plan Plan) error {
+	doc, err := planDocument(plan)
+	if err != nil {
+		return err
+	}
+
+	enabled := map[string]bool{}
+	for _, name := range plan.Features {
+		enabled[name] = true
+	}
+
+	problems := []string{}
+	seen := map[string]bool{}
+	for _, name := range plan.Features {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		feature, ok := GetFeature(name)
+		if !ok {
+			continue
+		}
+
+		for _, conflict := range feature.Conflicts {
+			// Report each pair once
+			if enabled[conflict] && name < conflict {
+				problems = append(problems, fmt.Sprintf("feature %s conflicts with %s", name, conflict))
+			}
+		}
+
+		for _, field := range feature.Fields {
+			if !fieldSet(doc, field) {
+				problems = append(problems, fmt.Sprintf("feature %s requires the field: %s", name, field))
+			}
+		}
+
+		for _, required := range feature.Secrets {
+			secret, found := enabledSecret(plan, enabled, required.Name)
+			if !found {
+				problems = append(problems, fmt.Sprintf("feature %s requires the secret: %s", name, required.Name))
+				continue
+			}
+
+			keys := map[string]bool{}
+			for _, key := range secret.Keys() {
+				keys[key] = true
+			}
+			for _, key := range required.Keys {
+				if !keys[key] {
+					problems = append(problems, fmt.Sprintf("feature %s requires the key %s in secret: %s", name, key, required.Name))
+				}
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("the plan is missing what its features require:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// enabledSecret finds a secret by name which has a filter for one of
+// the enabled features
+func enabledSecret(plan Plan, enabled map[string]bool, name string) (KeyValueNamespaceTuple, bool) {
+	for _, secret := range plan.Secrets {
+		if secret.Name != name {
+			continue
+		}
+		for _, filter := range secret.Filters {
+			if enabled[filter] {
+				return secret, true
+			}
+		}
+	}
+	return KeyValueNamespaceTuple{}, false
+}
+
+// fieldSet is true when the field at path has a value other than
+// its zero value
+func fieldSet(doc interface{}, path string) bool {
+	for _, key := range strings.Split(path, ".") {
+		m, ok := doc.(yaml.MapSlice)
+		if !ok {
+			return false
+		}
+		value, found := lookupKey(m, key)
+		if !found {
+			return false
+		}
+		doc = value
+	}
+	return doc != nil && doc != ""
+}
+
+func otherFeatures(features []string, name string) []string {
+	others := []string{}
+	for _, feature := range features {
+		if feature != name {
+			others = append(others, feature)
+		}
+	}
+	return others
+}
@@ -0,0 +1,146 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func examplePlan(t *testing.T) Plan {
+	data, err := ioutil.ReadFile("../../example.init.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := MergePlanFiles([]PlanFile{{Name: "example.init.yaml", Data: data}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *plan
+}
+
+func Test_ValidateFeatures_ExamplePlan(t *testing.T) {
+	featureSets := [][]string{
+		{DefaultFeature, GitHubFeature},
+		{DefaultFeature, GitLabFeature, Auth},
+		{DefaultFeature, ECRFeature, GitHubFeature, DODNS},
+	}
+
+	for _, features := range featureSets {
+		plan := examplePlan(t)
+		plan.Features = features
+
+		if err := ValidateFeatures(plan); err != nil {
+			t.Errorf("features %v want no error, got: %s", features, err.Error())
+		}
+	}
+}
+
+func Test_ValidateFeatures_MissingSecret(t *testing.T) {
+	plan := examplePlan(t)
+	plan.Features = []string{DefaultFeature, GitLabFeature, Auth}
+
+	secrets := []KeyValueNamespaceTuple{}
+	for _, secret := range plan.Secrets {
+		if secret.Name != "of-client-secret" && secret.Name != "gitlab-api-token" {
+			secrets = append(secrets, secret)
+		}
+	}
+	plan.Secrets = secrets
+
+	err := ValidateFeatures(plan)
+	if err == nil {
+		t.Fatalf("want error for missing secrets")
+	}
+
+	for _, want := range []string{
+		"feature scm_gitlab requires the secret: gitlab-api-token",
+		"feature auth requires the secret: of-client-secret",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("want %q in error, got: %s", want, err.Error())
+		}
+	}
+}
+
+func Test_ValidateFeatures_SecretWithoutFilter(t *testing.T) {
+	plan := examplePlan(t)
+	plan.Features = []string{DefaultFeature, GitHubFeature, Auth}
+
+	for i, secret := range plan.Secrets {
+		if secret.Name == "of-client-secret" {
+			plan.Secrets[i].Filters = []string{GitLabFeature}
+		}
+	}
+
+	err := ValidateFeatures(plan)
+	want := "feature auth requires the secret: of-client-secret"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("want %q in error, got: %v", want, err)
+	}
+}
+
+func Test_ValidateFeatures_MissingKeyAndField(t *testing.T) {
+	plan := examplePlan(t)
+	plan.Features = []string{DefaultFeature, GitHubFeature, Route53DNS}
+	plan.TLSConfig.Region = ""
+
+	for i, secret := range plan.Secrets {
+		if secret.Name == "private-key" {
+			plan.Secrets[i].Files[0].Name = "key.pem"
+		}
+	}
+
+	err := ValidateFeatures(plan)
+	if err == nil {
+		t.Fatalf("want error")
+	}
+
+	want := `the plan is missing what its features require:
+  feature scm_github requires the key private-key in secret: private-key
+  feature route53_dns01 requires the field: tls_config.region`
+	if err.Error() != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, err.Error())
+	}
+}
+
+func Test_ValidateFeatures_AzureDNSFields(t *testing.T) {
+	plan := examplePlan(t)
+	plan.Features = []string{DefaultFeature, GitHubFeature, AzureDNS}
+	plan.TLSConfig.ClientID = "app-id"
+	plan.TLSConfig.SubscriptionID = "sub-id"
+	plan.TLSConfig.TenantID = "tenant-id"
+
+	err := ValidateFeatures(plan)
+	want := `the plan is missing what its features require:
+  feature azure_dns01 requires the field: tls_config.resource_group
+  feature azure_dns01 requires the field: tls_config.hosted_zone`
+	if err == nil || err.Error() != want {
+		t.Errorf("want:\n%s\ngot:\n%v", want, err)
+	}
+}
+
+func Test_ValidateFeatures_Conflicts(t *testing.T) {
+	plan := examplePlan(t)
+	plan.Features = []string{DefaultFeature, GitHubFeature, GitLabFeature, GitHubFeature}
+
+	err := ValidateFeatures(plan)
+	want := "the plan is missing what its features require:\n  feature scm_github conflicts with scm_gitlab"
+	if err == nil || err.Error() != want {
+		t.Errorf("want:\n%s\ngot:\n%v", want, err)
+	}
+}
+
+func Test_FeatureFor(t *testing.T) {
+	feature, ok := FeatureFor("tls_config.dns_service", Route53)
+	if !ok || feature.Name != Route53DNS {
+		t.Errorf("want %s, got: %s", Route53DNS, feature.Name)
+	}
+
+	if _, ok := FeatureFor("scm", "bitbucket"); ok {
+		t.Errorf("want no feature for bitbucket")
+	}
+}
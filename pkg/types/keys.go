@@ -0,0 +1,241 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ValidateKeyGenerators checks the generator of each file and that
+// every public-key generator refers to a secret with a private key
+func ValidateKeyGenerators(secrets []KeyValueNamespaceTuple) error {
+	for _, secret := range secrets {
+		for _, file := range secret.Files {
+			if len(file.Generator) == 0 {
+				if len(file.PublicKeyOf) > 0 {
+					return fmt.Errorf("secret %s, file %s: public_key_of requires generator: %s", secret.Name, file.Name, PublicKeyGenerator)
+				}
+				continue
+			}
+
+			if len(file.ValueCommand) > 0 {
+				return fmt.Errorf("secret %s, file %s: set either generator or value_command, not both", secret.Name, file.Name)
+			}
+
+			if file.Generator == PublicKeyGenerator {
+				if _, err := privateKeyFile(secrets, file.PublicKeyOf); err != nil {
+					return fmt.Errorf("secret %s, file %s: %s", secret.Name, file.Name, err.Error())
+				}
+				continue
+			}
+
+			if !isPrivateKeyGenerator(file.Generator) {
+				return fmt.Errorf("secret %s, file %s: unsupported generator: %q", secret.Name, file.Name, file.Generator)
+			}
+		}
+	}
+	return nil
+}
+
+// GenerateKeyFiles writes any files with a generator which do not exist
+// yet, except for existing secrets. Private keys are written before the
+// public keys derived from them.
+func GenerateKeyFiles(secrets []KeyValueNamespaceTuple) error {
+	for _, secret := range secrets {
+		if secret.Existing {
+			continue
+		}
+		for _, file := range secret.Files {
+			if isPrivateKeyGenerator(file.Generator) {
+				if err := generatePrivateKeyFile(file); err != nil {
+					return fmt.Errorf("secret %s: %s", secret.Name, err.Error())
+				}
+			}
+		}
+	}
+
+	for _, secret := range secrets {
+		if secret.Existing {
+			continue
+		}
+		for _, file := range secret.Files {
+			if file.Generator == PublicKeyGenerator {
+				if err := generatePublicKeyFile(secrets, file); err != nil {
+					return fmt.Errorf("secret %s: %s", secret.Name, err.Error())
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func isPrivateKeyGenerator(generator string) bool {
+	switch generator {
+	case ECDSAP256Generator, RSA2048Generator, RSA4096Generator, Ed25519Generator:
+		return true
+	}
+	return false
+}
+
+// privateKeyFile finds the file holding a generated private key
+// within the secret with the given name
+func privateKeyFile(secrets []KeyValueNamespaceTuple, secretName string) (FileSecret, error) {
+	if len(secretName) == 0 {
+		return FileSecret{}, fmt.Errorf("public_key_of is required for generator: %s", PublicKeyGenerator)
+	}
+
+	for _, secret := range secrets {
+		if secret.Name != secretName {
+			continue
+		}
+		for _, file := range secret.Files {
+			if isPrivateKeyGenerator(file.Generator) {
+				return file, nil
+			}
+		}
+		return FileSecret{}, fmt.Errorf("secret %s has no file with a private key generator", secretName)
+	}
+
+	return FileSecret{}, fmt.Errorf("public_key_of refers to unknown secret: %s", secretName)
+}
+
+func generatePrivateKeyFile(file FileSecret) error {
+	filePath := file.ExpandValueFrom()
+	if _, err := os.Stat(filePath); err == nil {
+		fmt.Printf("%s exists, not running generator\n", filePath)
+		return nil
+	}
+
+	block, err := generatePrivateKey(file.Generator)
+	if err != nil {
+		return fmt.Errorf("error generating %s: %s", file.Generator, err.Error())
+	}
+
+	return writeKeyFile(filePath, block)
+}
+
+func generatePublicKeyFile(secrets []KeyValueNamespaceTuple, file FileSecret) error {
+	filePath := file.ExpandValueFrom()
+	if _, err := os.Stat(filePath); err == nil {
+		fmt.Printf("%s exists, not running generator\n", filePath)
+		return nil
+	}
+
+	privateFile, err := privateKeyFile(secrets, file.PublicKeyOf)
+	if err != nil {
+		return err
+	}
+
+	privateBytes, err := ioutil.ReadFile(privateFile.ExpandValueFrom())
+	if err != nil {
+		return err
+	}
+
+	block, err := publicKeyOf(privateBytes)
+	if err != nil {
+		return fmt.Errorf("error deriving public key from %s: %s", privateFile.ExpandValueFrom(), err.Error())
+	}
+
+	return writeKeyFile(filePath, block)
+}
+
+func generatePrivateKey(generator string) (*pem.Block, error) {
+	switch generator {
+	case ECDSAP256Generator:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}, nil
+	case RSA2048Generator, RSA4096Generator:
+		bits := 2048
+		if generator == RSA4096Generator {
+			bits = 4096
+		}
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, err
+		}
+		return &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}, nil
+	case Ed25519Generator:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return &pem.Block{Type: "PRIVATE KEY", Bytes: der}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported generator: %q", generator)
+}
+
+// publicKeyOf parses a PEM encoded private key and returns its
+// public key in PKIX form, as written by "openssl ec -pubout"
+func publicKeyOf(privateKeyPEM []byte) (*pem.Block, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	var public crypto.PublicKey
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		public = key.Public()
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		public = key.Public()
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type: %T", key)
+		}
+		public = signer.Public()
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type: %q", block.Type)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+	return &pem.Block{Type: "PUBLIC KEY", Bytes: der}, nil
+}
+
+func writeKeyFile(filePath string, block *pem.Block) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filePath, pem.EncodeToMemory(block), 0600)
+}
@@ -0,0 +1,147 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_GenerateKeyFiles_PrivateAndPublic(t *testing.T) {
+	generators := []string{ECDSAP256Generator, RSA2048Generator, Ed25519Generator}
+
+	for _, generator := range generators {
+		t.Run(generator, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "ofc-keys")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			privatePath := filepath.Join(dir, "tmp", "key")
+			publicPath := filepath.Join(dir, "tmp", "key.pub")
+
+			// The public key is listed first to check the ordering
+			secrets := []KeyValueNamespaceTuple{
+				{
+					Name: "jwt-public-key",
+					Files: []FileSecret{
+						{Name: "key.pub", ValueFrom: publicPath, Generator: PublicKeyGenerator, PublicKeyOf: "jwt-private-key"},
+					},
+				},
+				{
+					Name: "jwt-private-key",
+					Files: []FileSecret{
+						{Name: "key", ValueFrom: privatePath, Generator: generator},
+					},
+				},
+			}
+
+			if err := ValidateKeyGenerators(secrets); err != nil {
+				t.Fatalf("want no validation error, got: %s", err.Error())
+			}
+
+			if err := GenerateKeyFiles(secrets); err != nil {
+				t.Fatalf("want no error, got: %s", err.Error())
+			}
+
+			for _, keyPath := range []string{privatePath, publicPath} {
+				info, err := os.Stat(keyPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.Mode().Perm() != 0600 {
+					t.Errorf("%s want mode: %o, got: %o", keyPath, 0600, info.Mode().Perm())
+				}
+			}
+
+			publicBytes, _ := ioutil.ReadFile(publicPath)
+			block, _ := pem.Decode(publicBytes)
+			if block == nil || block.Type != "PUBLIC KEY" {
+				t.Fatalf("want a PUBLIC KEY PEM block, got: %q", string(publicBytes))
+			}
+			if _, err := x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+				t.Errorf("want a valid public key, got: %s", err.Error())
+			}
+		})
+	}
+}
+
+func Test_GenerateKeyFiles_KeepsExistingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ofc-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	privatePath := filepath.Join(dir, "key")
+	want := "existing"
+	ioutil.WriteFile(privatePath, []byte(want), 0600)
+
+	secrets := []KeyValueNamespaceTuple{
+		{
+			Name:  "jwt-private-key",
+			Files: []FileSecret{{Name: "key", ValueFrom: privatePath, Generator: ECDSAP256Generator}},
+		},
+	}
+
+	if err := GenerateKeyFiles(secrets); err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+
+	got, _ := ioutil.ReadFile(privatePath)
+	if string(got) != want {
+		t.Errorf("want file to be kept: %q, got: %q", want, string(got))
+	}
+}
+
+func Test_ValidateKeyGenerators_Errors(t *testing.T) {
+	tests := []struct {
+		title   string
+		file    FileSecret
+		wantErr string
+	}{
+		{
+			title:   "unknown generator",
+			file:    FileSecret{Name: "key", Generator: "dsa-private"},
+			wantErr: "unsupported generator",
+		},
+		{
+			title:   "generator and value_command",
+			file:    FileSecret{Name: "key", Generator: ECDSAP256Generator, ValueCommand: "openssl"},
+			wantErr: "not both",
+		},
+		{
+			title:   "public key of unknown secret",
+			file:    FileSecret{Name: "key.pub", Generator: PublicKeyGenerator, PublicKeyOf: "missing"},
+			wantErr: "unknown secret: missing",
+		},
+		{
+			title:   "public key without public_key_of",
+			file:    FileSecret{Name: "key.pub", Generator: PublicKeyGenerator},
+			wantErr: "public_key_of is required",
+		},
+		{
+			title:   "public_key_of without generator",
+			file:    FileSecret{Name: "key.pub", PublicKeyOf: "jwt-private-key"},
+			wantErr: "requires generator",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			secrets := []KeyValueNamespaceTuple{{Name: "jwt", Files: []FileSecret{test.file}}}
+
+			err := ValidateKeyGenerators(secrets)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("want error containing: %q, got: %v", test.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	execute "github.com/alexellis/go-execute/pkg/v1"
+	"github.com/openfaas/ofc-bootstrap/version"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// ManagedByLabel is set to ofc-bootstrap on every object it creates
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	// PlanHashLabel records the hash of the plan which created an object
+	PlanHashLabel = "ofc-bootstrap.openfaas.com/plan-hash"
+	// VersionLabel records the version of ofc-bootstrap which created an object
+	VersionLabel = "ofc-bootstrap.openfaas.com/version"
+
+	// ManagedByValue is the value of ManagedByLabel
+	ManagedByValue = "ofc-bootstrap"
+)
+
+var invalidLabelChars = regexp.MustCompile("[^A-Za-z0-9_.-]")
+
+// StandardLabels are applied to every object created by apply, so that
+// they can be found again by uninstall, diff and audit tooling
+func StandardLabels(plan Plan) map[string]string {
+	return map[string]string{
+		ManagedByLabel: ManagedByValue,
+		PlanHashLabel:  PlanHash(plan),
+		VersionLabel:   labelValue(version.GetVersion()),
+	}
+}
+
+// PlanHash is a short, stable hash of the contents of a plan. Literal
+// secret values are stripped first, so the label cannot be used to
+// check a guess of a secret.
+func PlanHash(plan Plan) string {
+	out, _ := yaml.Marshal(StripSecretValues(plan))
+	sum := sha256.Sum256(out)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// labelValue makes a value safe to use as a Kubernetes label value
+func labelValue(value string) string {
+	value = invalidLabelChars.ReplaceAllString(value, "-")
+	if len(value) > 63 {
+		value = value[:63]
+	}
+	return strings.Trim(value, "-_.")
+}
+
+// BuildSecretMetadataTasks labels and annotates a secret after it has
+// been created by BuildSecretTask. The standard labels take precedence
+// over any labels given in the plan.
+func BuildSecretMetadataTasks(kvn KeyValueNamespaceTuple, standardLabels map[string]string) []execute.ExecTask {
+	labels := map[string]string{}
+	for k, v := range kvn.Labels {
+		labels[k] = v
+	}
+	for k, v := range standardLabels {
+		labels[k] = v
+	}
+
+	tasks := []execute.ExecTask{}
+	if len(labels) > 0 {
+		tasks = append(tasks, execute.ExecTask{
+			Command:     "kubectl",
+			Args:        append([]string{"label", "secret", "-n=" + kvn.Namespace, kvn.Name, "--overwrite"}, keyValueArgs(labels)...),
+			StreamStdio: false,
+		})
+	}
+
+	if len(kvn.Annotations) > 0 {
+		tasks = append(tasks, execute.ExecTask{
+			Command:     "kubectl",
+			Args:        append([]string{"annotate", "secret", "-n=" + kvn.Namespace, kvn.Name, "--overwrite"}, keyValueArgs(kvn.Annotations)...),
+			StreamStdio: false,
+		})
+	}
+
+	return tasks
+}
+
+// BuildLabelFileTask labels every object in a manifest file
+// which has already been applied to the cluster
+func BuildLabelFileTask(filePath string, labels map[string]string) execute.ExecTask {
+	return execute.ExecTask{
+		Command:     "kubectl",
+		Args:        append([]string{"label", "-f", filePath, "--overwrite"}, keyValueArgs(labels)...),
+		StreamStdio: false,
+	}
+}
+
+// BuildLabelObjectsTask labels objects of one kind by name, for objects
+// created outside of a manifest file, such as by a script. The namespace
+// is left out for cluster-scoped kinds.
+func BuildLabelObjectsTask(kind, namespace string, names []string, labels map[string]string) execute.ExecTask {
+	args := append([]string{"label", kind}, names...)
+	if len(namespace) > 0 {
+		args = append(args, "-n="+namespace)
+	}
+	args = append(args, "--overwrite")
+
+	return execute.ExecTask{
+		Command:     "kubectl",
+		Args:        append(args, keyValueArgs(labels)...),
+		StreamStdio: false,
+	}
+}
+
+func keyValueArgs(values map[string]string) []string {
+	keys := []string{}
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	args := []string{}
+	for _, k := range keys {
+		args = append(args, fmt.Sprintf("%s=%s", k, values[k]))
+	}
+	return args
+}
@@ -0,0 +1,104 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_PlanHash_StableAndChangesWithPlan(t *testing.T) {
+	plan := Plan{RootDomain: "ofc.example.com"}
+
+	first := PlanHash(plan)
+	if first != PlanHash(plan) {
+		t.Errorf("want the same hash for the same plan")
+	}
+	if len(first) != 16 {
+		t.Errorf("want a hash of 16 characters, got: %d", len(first))
+	}
+
+	plan.RootDomain = "ofc.example.org"
+	if first == PlanHash(plan) {
+		t.Errorf("want a different hash when the plan changes")
+	}
+}
+
+func Test_PlanHash_IgnoresLiteralSecretValues(t *testing.T) {
+	plan := Plan{Secrets: []KeyValueNamespaceTuple{{
+		Name:     "s3-secret-key",
+		Literals: []KeyValueTuple{{Name: "s3-secret-key", Value: "first-guess"}},
+	}}}
+	first := PlanHash(plan)
+
+	plan.Secrets[0].Literals[0].Value = "second-guess"
+	if first != PlanHash(plan) {
+		t.Errorf("want the hash to be the same whatever the literal secret value")
+	}
+}
+
+func Test_BuildLabelObjectsTask(t *testing.T) {
+	labels := map[string]string{ManagedByLabel: ManagedByValue, PlanHashLabel: "abc"}
+
+	task := BuildLabelObjectsTask("secret", "openfaas-fn", []string{"basic-auth-user", "basic-auth-password"}, labels)
+	want := []string{"label", "secret", "basic-auth-user", "basic-auth-password", "-n=openfaas-fn", "--overwrite",
+		ManagedByLabel + "=" + ManagedByValue, PlanHashLabel + "=abc"}
+	if !reflect.DeepEqual(task.Args, want) {
+		t.Errorf("want: %v, got: %v", want, task.Args)
+	}
+
+	task = BuildLabelObjectsTask("namespace", "", []string{"openfaas"}, labels)
+	for _, arg := range task.Args {
+		if arg == "-n=" {
+			t.Errorf("want no namespace for cluster-scoped objects, got: %v", task.Args)
+		}
+	}
+}
+
+func Test_labelValue(t *testing.T) {
+	tests := map[string]string{
+		"0.11.0":         "0.11.0",
+		"dev":            "dev",
+		"0.11.0+dirty/x": "0.11.0-dirty-x",
+		"-leading":       "leading",
+	}
+
+	for value, want := range tests {
+		if got := labelValue(value); got != want {
+			t.Errorf("labelValue(%q) want: %q, got: %q", value, want, got)
+		}
+	}
+}
+
+func Test_BuildSecretMetadataTasks(t *testing.T) {
+	kvn := KeyValueNamespaceTuple{
+		Name:        "basic-auth",
+		Namespace:   "openfaas",
+		Labels:      map[string]string{"team": "platform", ManagedByLabel: "someone-else"},
+		Annotations: map[string]string{"owner": "ops@example.com"},
+	}
+
+	tasks := BuildSecretMetadataTasks(kvn, map[string]string{ManagedByLabel: ManagedByValue})
+	if len(tasks) != 2 {
+		t.Fatalf("want 2 tasks, got: %d", len(tasks))
+	}
+
+	wantLabel := []string{"label", "secret", "-n=openfaas", "basic-auth", "--overwrite",
+		"app.kubernetes.io/managed-by=ofc-bootstrap", "team=platform"}
+	if !reflect.DeepEqual(tasks[0].Args, wantLabel) {
+		t.Errorf("want: %v, got: %v", wantLabel, tasks[0].Args)
+	}
+
+	wantAnnotate := []string{"annotate", "secret", "-n=openfaas", "basic-auth", "--overwrite", "owner=ops@example.com"}
+	if !reflect.DeepEqual(tasks[1].Args, wantAnnotate) {
+		t.Errorf("want: %v, got: %v", wantAnnotate, tasks[1].Args)
+	}
+}
+
+func Test_BuildSecretMetadataTasks_NoMetadata(t *testing.T) {
+	tasks := BuildSecretMetadataTasks(KeyValueNamespaceTuple{Name: "basic-auth"}, map[string]string{})
+	if len(tasks) != 0 {
+		t.Errorf("want no tasks, got: %d", len(tasks))
+	}
+}
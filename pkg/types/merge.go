@@ -4,64 +4,455 @@
 package types
 
 import (
+	"bytes"
 	"fmt"
+	"reflect"
+	"strings"
 
-	"github.com/imdario/mergo"
-	"github.com/jinzhu/copier"
+	yaml "gopkg.in/yaml.v2"
 )
 
-// MergePlans combines one or more plan with a manual merge for
-// the list of secrets.
-func MergePlans(plans []Plan) (*Plan, error) {
-	var err error
-	masterPlan := &Plan{}
+const (
+	// RemoveMarker deletes a field set by an earlier plan when given
+	// as its value, or a secret when set as "$remove: true"
+	RemoveMarker = "$remove"
+
+	// ReplaceMarker as the first item of an appended list, replaces the
+	// list from earlier plans instead of appending to it
+	ReplaceMarker = "$replace"
+
+	// profilesKey holds the named overlays of a plan
+	profilesKey = "profiles"
+)
+
+// appendLists are merged by appending unique items, all
+// other lists are replaced by the last plan to set them
+var appendLists = map[string]bool{
+	"features":                    true,
+	"deployment.custom_templates": true,
+}
+
+// keyedLists are merged item by item, matched by the given key
+var keyedLists = map[string]string{
+	"secrets": "name",
+}
+
+// PlanFile is the content of a plan file and the name it was loaded from
+type PlanFile struct {
+	Name string
+	Data []byte
 
+	// Sensitive values were interpolated into Data from the environment
+	Sensitive []string
+
+	// Source describes where Data was read from, i.e. a checksum or commit
+	Source string
+}
+
+// MergePlans combines one or more plan in order. Since the plans have
+// already been decoded, fields with a zero value are not merged, use
+// MergePlanFiles to set a field back to false or to remove it.
+func MergePlans(plans []Plan) (*Plan, error) {
 	if len(plans) == 1 {
 		return &plans[0], nil
 	}
 
 	if len(plans) == 0 {
-		return masterPlan, fmt.Errorf("at least one plan is required")
+		return &Plan{}, fmt.Errorf("at least one plan is required")
 	}
 
-	for _, plan := range plans {
-		mergeErr := mergo.Merge(masterPlan, plan, mergo.WithOverride)
-		if mergeErr != nil {
-			return masterPlan, mergeErr
+	files := []PlanFile{}
+	for i, plan := range plans {
+		out, err := yaml.Marshal(plan)
+		if err != nil {
+			return &Plan{}, err
 		}
+		files = append(files, PlanFile{Name: fmt.Sprintf("plan %d", i+1), Data: out})
+	}
+
+	return MergePlanFiles(files, "")
+}
+
+// MergePlanFiles combines one or more plan files in order, later files
+// take precedence. Maps are merged key by key, scalars including false are
+// replaced, features and custom_templates are appended to, secrets are
+// merged by name and all other lists are replaced. A value of null or
+// "$remove" deletes a field, and "$remove: true" deletes a secret. Each
+// file is decoded strictly first, so unknown fields are rejected.
+//
+// When profile is given, the overlay of that name under "profiles" is
+// merged last with the same rules.
+func MergePlanFiles(files []PlanFile, profile string) (*Plan, error) {
+	doc, _, err := mergeDocuments(files, profile)
+	if err != nil {
+		return &Plan{}, err
+	}
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return &Plan{}, err
 	}
 
-	patchSecrets(masterPlan, plans)
+	plan := Plan{}
+	if err := yaml.Unmarshal(out, &plan); err != nil {
+		return &Plan{}, err
+	}
 
-	return masterPlan, err
+	return &plan, nil
+}
+
+// ExplainPlanFiles merges the plan files with MergePlanFiles and returns
+// the result as YAML, with each field annotated with the file that last
+// set it. Secret literals with a sensitive value are redacted.
+func ExplainPlanFiles(files []PlanFile, profile string) (string, error) {
+	doc, provenance, err := mergeDocuments(files, profile)
+	if err != nil {
+		return "", err
+	}
+
+	sensitive := []string{}
+	for _, file := range files {
+		sensitive = append(sensitive, file.Sensitive...)
+	}
+
+	buf := bytes.Buffer{}
+	for _, line := range explainMap(doc, "", provenance, sensitive) {
+		buf.WriteString(line + "\n")
+	}
+	return buf.String(), nil
 }
 
-func patchSecrets(masterPlan *Plan, plans []Plan) {
-	masterList := []KeyValueNamespaceTuple{}
+func mergeDocuments(files []PlanFile, profile string) (yaml.MapSlice, map[string]string, error) {
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("at least one plan is required")
+	}
+
+	doc := yaml.MapSlice{}
+	provenance := map[string]string{}
 
-	// Read each plan
-	for _, plan := range plans {
+	for _, file := range files {
+		if err := ValidatePlanFile(file); err != nil {
+			return nil, nil, err
+		}
+
+		src := yaml.MapSlice{}
+		if err := yaml.Unmarshal(file.Data, &src); err != nil {
+			return nil, nil, fmt.Errorf("unmarshal of %s gave error: %s", file.Name, err.Error())
+		}
+
+		doc = mergeMap(doc, src, "", file.Name, provenance)
+	}
+
+	if len(profile) > 0 {
+		return applyProfile(doc, profile, provenance)
+	}
 
-		// Process each secret
-		for _, v := range plan.Secrets {
+	return doc, provenance, nil
+}
+
+// applyProfile merges the named overlay from "profiles" into the
+// document, which is then returned without its profiles
+func applyProfile(doc yaml.MapSlice, profile string, provenance map[string]string) (yaml.MapSlice, map[string]string, error) {
+	profiles, _ := lookupKey(doc, profilesKey)
+	profileMap, _ := profiles.(yaml.MapSlice)
+
+	overlay, found := lookupKey(profileMap, profile)
+	if !found {
+		names := []string{}
+		for _, item := range profileMap {
+			names = append(names, fmt.Sprintf("%v", item.Key))
+		}
+		if len(names) == 0 {
+			return nil, nil, fmt.Errorf("profile %q not found, no profiles are defined", profile)
+		}
+		return nil, nil, fmt.Errorf("profile %q not found, use one of: %s", profile, strings.Join(names, ", "))
+	}
+
+	overlayMap, ok := overlay.(yaml.MapSlice)
+	if !ok && overlay != nil {
+		return nil, nil, fmt.Errorf("profile %q must be a map of plan fields", profile)
+	}
+
+	doc = deleteKey(doc, profilesKey)
+	clearProvenance(provenance, profilesKey)
+
+	overlayMap = deleteKey(overlayMap, profilesKey)
+	doc = mergeMap(doc, overlayMap, "", "profile "+profile, provenance)
+
+	return doc, provenance, nil
+}
+
+func mergeMap(dst, src yaml.MapSlice, path, source string, provenance map[string]string) yaml.MapSlice {
+	for _, item := range src {
+		key := fmt.Sprintf("%v", item.Key)
+		childPath := joinPath(path, key)
+
+		if isRemove(item.Value) {
+			dst = deleteKey(dst, key)
+			clearProvenance(provenance, childPath)
+			continue
+		}
+
+		existing, found := lookupKey(dst, key)
+		value := item.Value
+
+		switch srcValue := item.Value.(type) {
+		case yaml.MapSlice:
+			if dstValue, ok := existing.(yaml.MapSlice); ok && found {
+				value = mergeMap(dstValue, srcValue, childPath, source, provenance)
+			} else {
+				clearProvenance(provenance, childPath)
+				value = mergeMap(yaml.MapSlice{}, srcValue, childPath, source, provenance)
+			}
+		case []interface{}:
+			dstValue, _ := existing.([]interface{})
+			value = mergeList(dstValue, srcValue, childPath, source, provenance)
+		default:
+			clearProvenance(provenance, childPath)
+			provenance[childPath] = source
+		}
+
+		dst = setKey(dst, key, value)
+	}
+
+	return dst
+}
+
+func mergeList(dst, src []interface{}, path, source string, provenance map[string]string) []interface{} {
+	rule := genericPath(path)
+
+	if key, ok := keyedLists[rule]; ok {
+		return mergeKeyedList(dst, src, path, key, source, provenance)
+	}
+
+	if appendLists[rule] {
+		if len(src) > 0 && src[0] == ReplaceMarker {
+			dst = []interface{}{}
+			src = src[1:]
+			clearProvenance(provenance, path)
+		}
+
+		for _, item := range src {
+			if !containsItem(dst, item) {
+				provenance[fmt.Sprintf("%s[%d]", path, len(dst))] = source
+				dst = append(dst, item)
+			}
+		}
+		return dst
+	}
+
+	clearProvenance(provenance, path)
+	provenance[path] = source
+	return src
+}
 
-			// Apply to master list
-			index := -1
-			for i, mv := range masterList {
-				if mv.Name == v.Name {
+func mergeKeyedList(dst, src []interface{}, path, key, source string, provenance map[string]string) []interface{} {
+	for _, item := range src {
+		srcItem, ok := item.(yaml.MapSlice)
+		if !ok {
+			continue
+		}
+
+		name, _ := lookupKey(srcItem, key)
+		itemPath := fmt.Sprintf("%s[%v]", path, name)
+
+		index := -1
+		for i, existing := range dst {
+			if existingItem, ok := existing.(yaml.MapSlice); ok {
+				if existingName, _ := lookupKey(existingItem, key); reflect.DeepEqual(existingName, name) {
 					index = i
 					break
 				}
 			}
+		}
 
-			if index == -1 {
-				item := KeyValueNamespaceTuple{}
-				copier.Copy(&item, &v)
-				masterList = append(masterList, item)
-			} else {
-				copier.Copy(&masterList[index], &v)
+		if remove, _ := lookupKey(srcItem, RemoveMarker); remove == true {
+			if index > -1 {
+				dst = append(dst[:index], dst[index+1:]...)
+			}
+			clearProvenance(provenance, itemPath)
+			continue
+		}
+		srcItem = deleteKey(srcItem, RemoveMarker)
+
+		if index == -1 {
+			dst = append(dst, mergeMap(yaml.MapSlice{}, srcItem, itemPath, source, provenance))
+		} else {
+			dst[index] = mergeMap(dst[index].(yaml.MapSlice), srcItem, itemPath, source, provenance)
+		}
+	}
+
+	return dst
+}
+
+// explainMap renders a merged document as YAML lines, with a comment
+// naming the file which set each value
+func explainMap(doc yaml.MapSlice, path string, provenance map[string]string, sensitive []string) []string {
+	lines := []string{}
+
+	for _, item := range doc {
+		key := fmt.Sprintf("%v", item.Key)
+		childPath := joinPath(path, key)
+
+		switch value := item.Value.(type) {
+		case yaml.MapSlice:
+			if len(value) == 0 {
+				lines = append(lines, fmt.Sprintf("%s: {}  # %s", key, sourceOf(provenance, childPath)))
+				continue
+			}
+			lines = append(lines, key+":")
+			lines = append(lines, indent(explainMap(value, childPath, provenance, sensitive), "  ")...)
+		case []interface{}:
+			if len(value) == 0 {
+				lines = append(lines, fmt.Sprintf("%s: []  # %s", key, sourceOf(provenance, childPath)))
+				continue
 			}
+			lines = append(lines, key+":")
+			lines = append(lines, indent(explainList(value, childPath, provenance, sensitive), "  ")...)
+		default:
+			if genericPath(childPath) == "secrets.literals.value" && containsAny(fmt.Sprintf("%v", value), sensitive) {
+				value = RedactedValue
+			}
+			lines = append(lines, fmt.Sprintf("%s: %s  # %s", key, scalarYAML(value), sourceOf(provenance, childPath)))
+		}
+	}
+
+	return lines
+}
+
+func explainList(list []interface{}, path string, provenance map[string]string, sensitive []string) []string {
+	lines := []string{}
+	keyName := keyedLists[genericPath(path)]
+
+	for i, item := range list {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+
+		switch value := item.(type) {
+		case yaml.MapSlice:
+			if len(keyName) > 0 {
+				name, _ := lookupKey(value, keyName)
+				itemPath = fmt.Sprintf("%s[%v]", path, name)
+			}
+
+			itemLines := explainMap(value, itemPath, provenance, sensitive)
+			for j, line := range itemLines {
+				if j == 0 {
+					lines = append(lines, "- "+line)
+				} else {
+					lines = append(lines, "  "+line)
+				}
+			}
+		default:
+			lines = append(lines, fmt.Sprintf("- %s  # %s", scalarYAML(value), sourceOf(provenance, itemPath)))
+		}
+	}
+
+	return lines
+}
+
+// sourceOf finds the file which set a path, or the closest parent path
+func sourceOf(provenance map[string]string, path string) string {
+	for len(path) > 0 {
+		if source, ok := provenance[path]; ok {
+			return source
+		}
+
+		cut := strings.LastIndexAny(path, ".[")
+		if cut == -1 {
+			break
+		}
+		path = path[:cut]
+	}
+	return "unknown"
+}
+
+func scalarYAML(value interface{}) string {
+	out, err := yaml.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
+
+func indent(lines []string, prefix string) []string {
+	out := []string{}
+	for _, line := range lines {
+		out = append(out, prefix+line)
+	}
+	return out
+}
+
+func isRemove(value interface{}) bool {
+	return value == nil || value == RemoveMarker
+}
+
+func joinPath(path, key string) string {
+	if len(path) == 0 {
+		return key
+	}
+	return path + "." + key
+}
+
+// genericPath removes list indexes and keys from a path,
+// so that it can be matched against the merge rules
+func genericPath(path string) string {
+	out := strings.Builder{}
+	depth := 0
+	for _, r := range path {
+		switch {
+		case r == '[':
+			depth++
+		case r == ']':
+			depth--
+		case depth == 0:
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
+func clearProvenance(provenance map[string]string, path string) {
+	for key := range provenance {
+		if key == path || strings.HasPrefix(key, path+".") || strings.HasPrefix(key, path+"[") {
+			delete(provenance, key)
+		}
+	}
+}
+
+func lookupKey(m yaml.MapSlice, key string) (interface{}, bool) {
+	for _, item := range m {
+		if fmt.Sprintf("%v", item.Key) == key {
+			return item.Value, true
+		}
+	}
+	return nil, false
+}
+
+func setKey(m yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range m {
+		if fmt.Sprintf("%v", item.Key) == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml.MapItem{Key: key, Value: value})
+}
+
+func deleteKey(m yaml.MapSlice, key string) yaml.MapSlice {
+	out := yaml.MapSlice{}
+	for _, item := range m {
+		if fmt.Sprintf("%v", item.Key) != key {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func containsItem(list []interface{}, item interface{}) bool {
+	for _, existing := range list {
+		if reflect.DeepEqual(existing, item) {
+			return true
 		}
 	}
-	masterPlan.Secrets = masterList
+	return false
 }
@@ -4,6 +4,7 @@
 package types
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -197,3 +198,195 @@ func Test_mergePlans_CombineSecretsMatchingNamesLiterals(t *testing.T) {
 		t.Errorf("Secret merged incorrectly, want: %s, got %s", wantPass, planOut.Secrets[0].Literals[0].Value)
 	}
 }
+
+func Test_MergePlanFiles_ExplicitFalseOverrides(t *testing.T) {
+	files := []PlanFile{
+		{Name: "base.yaml", Data: []byte("tls: true\nenable_oauth: true\n")},
+		{Name: "dev.yaml", Data: []byte("tls: false\n")},
+	}
+
+	planOut, err := MergePlanFiles(files, "")
+	if err != nil {
+		t.Fatalf("Got error, expected no error: %s", err.Error())
+	}
+
+	if planOut.TLS {
+		t.Errorf("TLS want: false, but got: %v", planOut.TLS)
+	}
+	if !planOut.EnableOAuth {
+		t.Errorf("EnableOAuth want: true, but got: %v", planOut.EnableOAuth)
+	}
+}
+
+func Test_MergePlanFiles_FeaturesAppendAndReplace(t *testing.T) {
+	files := []PlanFile{
+		{Name: "base.yaml", Data: []byte("features: [one, two]\n")},
+		{Name: "prod.yaml", Data: []byte("features: [two, three]\n")},
+	}
+
+	planOut, err := MergePlanFiles(files, "")
+	if err != nil {
+		t.Fatalf("Got error, expected no error: %s", err.Error())
+	}
+
+	want := "one,two,three"
+	if got := strings.Join(planOut.Features, ","); got != want {
+		t.Errorf("Features want: %s, but got: %s", want, got)
+	}
+
+	files = append(files, PlanFile{Name: "reset.yaml", Data: []byte("features: [$replace, four]\n")})
+	planOut, err = MergePlanFiles(files, "")
+	if err != nil {
+		t.Fatalf("Got error, expected no error: %s", err.Error())
+	}
+
+	want = "four"
+	if got := strings.Join(planOut.Features, ","); got != want {
+		t.Errorf("Features want: %s, but got: %s", want, got)
+	}
+}
+
+func Test_MergePlanFiles_RemoveFields(t *testing.T) {
+	files := []PlanFile{
+		{Name: "base.yaml", Data: []byte("customers_url: https://example.com/CUSTOMERS\nbuild_branch: main\ns3:\n  s3_bucket: pipeline\n")},
+		{Name: "dev.yaml", Data: []byte("customers_url: null\nbuild_branch: $remove\ns3:\n  s3_bucket: ~\n")},
+	}
+
+	planOut, err := MergePlanFiles(files, "")
+	if err != nil {
+		t.Fatalf("Got error, expected no error: %s", err.Error())
+	}
+
+	if planOut.CustomersURL != "" || planOut.BuildBranch != "" || planOut.S3.Bucket != "" {
+		t.Errorf("want fields removed, got: %q, %q, %q", planOut.CustomersURL, planOut.BuildBranch, planOut.S3.Bucket)
+	}
+}
+
+func Test_MergePlanFiles_SecretsMergedByNameAndRemoved(t *testing.T) {
+	files := []PlanFile{
+		{Name: "base.yaml", Data: []byte(`secrets:
+- name: one
+  namespace: openfaas
+  filters: [default]
+- name: two
+  namespace: openfaas
+`)},
+		{Name: "prod.yaml", Data: []byte(`secrets:
+- name: one
+  namespace: openfaas-fn
+- name: two
+  $remove: true
+`)},
+	}
+
+	planOut, err := MergePlanFiles(files, "")
+	if err != nil {
+		t.Fatalf("Got error, expected no error: %s", err.Error())
+	}
+
+	if len(planOut.Secrets) != 1 {
+		t.Fatalf("Secrets want length %d, but got: %d", 1, len(planOut.Secrets))
+	}
+
+	secret := planOut.Secrets[0]
+	if secret.Namespace != "openfaas-fn" {
+		t.Errorf("Namespace want: %s, but got: %s", "openfaas-fn", secret.Namespace)
+	}
+	if len(secret.Filters) != 1 || secret.Filters[0] != DefaultFeature {
+		t.Errorf("Filters want: [default], but got: %v", secret.Filters)
+	}
+}
+
+func Test_ExplainPlanFiles(t *testing.T) {
+	files := []PlanFile{
+		{Name: "base.yaml", Data: []byte("root_domain: example.com\nfeatures: [one]\ntls_config:\n  email: a@example.com\n  dns_service: digitalocean\n")},
+		{Name: "prod.yaml", Data: []byte("features: [two]\ntls_config:\n  dns_service: route53\n")},
+	}
+
+	got, err := ExplainPlanFiles(files, "")
+	if err != nil {
+		t.Fatalf("Got error, expected no error: %s", err.Error())
+	}
+
+	wantLines := []string{
+		"root_domain: example.com  # base.yaml",
+		"  - one  # base.yaml",
+		"  - two  # prod.yaml",
+		"  email: a@example.com  # base.yaml",
+		"  dns_service: route53  # prod.yaml",
+	}
+	for _, want := range wantLines {
+		if !strings.Contains(got, want+"\n") {
+			t.Errorf("want line %q in:\n%s", want, got)
+		}
+	}
+}
+
+func Test_MergePlanFiles_Profile(t *testing.T) {
+	files := []PlanFile{
+		{Name: "init.yaml", Data: []byte(`root_domain: staging.example.com
+tls: false
+features: [one]
+tls_config:
+  issuer_type: staging
+  email: a@example.com
+profiles:
+  prod:
+    root_domain: example.com
+    tls: true
+    features: [two]
+    tls_config:
+      issuer_type: prod
+`)},
+	}
+
+	planOut, err := MergePlanFiles(files, "prod")
+	if err != nil {
+		t.Fatalf("Got error, expected no error: %s", err.Error())
+	}
+
+	if planOut.RootDomain != "example.com" || !planOut.TLS {
+		t.Errorf("want prod overlay, got root_domain: %s, tls: %v", planOut.RootDomain, planOut.TLS)
+	}
+	if planOut.TLSConfig.IssuerType != "prod" || planOut.TLSConfig.Email != "a@example.com" {
+		t.Errorf("want tls_config merged, got: %+v", planOut.TLSConfig)
+	}
+	if got := strings.Join(planOut.Features, ","); got != "one,two" {
+		t.Errorf("Features want: one,two, but got: %s", got)
+	}
+	if len(planOut.Profiles) != 0 {
+		t.Errorf("want profiles removed once one is selected, got: %d", len(planOut.Profiles))
+	}
+
+	planOut, err = MergePlanFiles(files, "")
+	if err != nil {
+		t.Fatalf("Got error, expected no error: %s", err.Error())
+	}
+	if planOut.RootDomain != "staging.example.com" {
+		t.Errorf("want no overlay without a profile, got root_domain: %s", planOut.RootDomain)
+	}
+
+	explained, err := ExplainPlanFiles(files, "prod")
+	if err != nil {
+		t.Fatalf("Got error, expected no error: %s", err.Error())
+	}
+	if !strings.Contains(explained, "root_domain: example.com  # profile prod\n") {
+		t.Errorf("want the profile named as the source, got:\n%s", explained)
+	}
+}
+
+func Test_MergePlanFiles_UnknownProfile(t *testing.T) {
+	files := []PlanFile{
+		{Name: "init.yaml", Data: []byte("profiles:\n  dev: {}\n  prod: {}\n")},
+	}
+
+	_, err := MergePlanFiles(files, "staging")
+	if err == nil {
+		t.Fatalf("want an error for an unknown profile")
+	}
+
+	want := `profile "staging" not found, use one of: dev, prod`
+	if err.Error() != want {
+		t.Errorf("want error: %s, got: %s", want, err.Error())
+	}
+}
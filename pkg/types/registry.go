@@ -0,0 +1,168 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DockerHubDomain is the domain of images without one, such as ofctest/fn
+const DockerHubDomain = "docker.io"
+
+var (
+	// domainComponentPattern and pathComponentPattern follow the
+	// grammar of github.com/docker/distribution/reference
+	domainComponentPattern = regexp.MustCompile(`^(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])$`)
+	pathComponentPattern   = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*$`)
+)
+
+// RegistryPrefix is the registry plan value parsed as the prefix of an
+// image reference: <host>[:<port>]/<namespace>/
+type RegistryPrefix struct {
+	Host      string
+	Port      string
+	Namespace string
+}
+
+// Domain is the host and port of the registry
+func (r RegistryPrefix) Domain() string {
+	if len(r.Port) > 0 {
+		return r.Host + ":" + r.Port
+	}
+	return r.Host
+}
+
+// String is the normalised prefix, with the domain and a trailing slash
+// i.e. docker.io/ofctest/ for ofctest/
+func (r RegistryPrefix) String() string {
+	if len(r.Namespace) == 0 {
+		return r.Domain() + "/"
+	}
+	return r.Domain() + "/" + r.Namespace + "/"
+}
+
+// RegistryPrefix parses the registry of the plan
+func (p Plan) RegistryPrefix() (RegistryPrefix, error) {
+	return ParseRegistry(p.Registry)
+}
+
+// ParseRegistry parses a registry plan value such as docker.io/ofctest/,
+// ofctest/ or registry.example.com:5000/team/. The domain is detected in
+// the same way as the Docker CLI, and Docker Hub needs a namespace. When
+// the value is invalid, the error suggests a valid value if one can be
+// derived from it.
+func ParseRegistry(value string) (RegistryPrefix, error) {
+	prefix, err := parseRegistry(value)
+	if err == nil {
+		return prefix, nil
+	}
+
+	if suggestion, ok := suggestRegistry(value); ok && suggestion != value {
+		return RegistryPrefix{}, fmt.Errorf("%s, did you mean: %q?", err.Error(), suggestion)
+	}
+	return RegistryPrefix{}, err
+}
+
+func parseRegistry(value string) (RegistryPrefix, error) {
+	if len(strings.TrimSpace(value)) == 0 {
+		return RegistryPrefix{}, fmt.Errorf("registry is empty, set it to the prefix for images i.e. docker.io/<user>/")
+	}
+	if value != strings.TrimSpace(value) {
+		return RegistryPrefix{}, fmt.Errorf("registry %q has leading or trailing whitespace", value)
+	}
+	if strings.Contains(value, "://") {
+		return RegistryPrefix{}, fmt.Errorf("registry %q must not have a scheme", value)
+	}
+	if strings.ContainsAny(value, "@") {
+		return RegistryPrefix{}, fmt.Errorf("registry %q must not have a digest", value)
+	}
+	if !strings.HasSuffix(value, "/") {
+		return RegistryPrefix{}, fmt.Errorf("registry %q must end with \"/\"", value)
+	}
+
+	components := strings.Split(strings.TrimSuffix(value, "/"), "/")
+
+	prefix := RegistryPrefix{Host: DockerHubDomain}
+	first := components[0]
+	if strings.ContainsAny(first, ".:") || first == "localhost" {
+		host, port, err := parseDomain(value, first)
+		if err != nil {
+			return RegistryPrefix{}, err
+		}
+		prefix.Host = NormaliseRegistryHost(host)
+		prefix.Port = port
+		components = components[1:]
+	}
+
+	for _, component := range components {
+		if len(component) == 0 {
+			return RegistryPrefix{}, fmt.Errorf("registry %q has an empty path component", value)
+		}
+		if strings.Contains(component, ":") {
+			return RegistryPrefix{}, fmt.Errorf("registry %q must not have a tag", value)
+		}
+		if component != strings.ToLower(component) {
+			return RegistryPrefix{}, fmt.Errorf("registry %q must be lower case", value)
+		}
+		if !pathComponentPattern.MatchString(component) {
+			return RegistryPrefix{}, fmt.Errorf("registry %q has an invalid path component %q, use a-z, 0-9 and the separators \".\", \"_\", \"__\" or \"-\" between them", value, component)
+		}
+	}
+
+	prefix.Namespace = strings.Join(components, "/")
+	if prefix.Host == DockerHubDomain && len(prefix.Namespace) == 0 {
+		return RegistryPrefix{}, fmt.Errorf("registry %q needs a user or organisation on Docker Hub i.e. docker.io/<user>/", value)
+	}
+	return prefix, nil
+}
+
+// NormaliseRegistryHost lower cases a registry host and maps the other
+// names of Docker Hub to DockerHubDomain
+func NormaliseRegistryHost(host string) string {
+	host = strings.ToLower(host)
+	switch host {
+	case "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return DockerHubDomain
+	}
+	return host
+}
+
+// parseDomain splits a domain into its lower case host and port
+func parseDomain(value, domain string) (string, string, error) {
+	host, port := domain, ""
+	if i := strings.LastIndex(domain, ":"); i > -1 {
+		host, port = domain[:i], domain[i+1:]
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", "", fmt.Errorf("registry %q has an invalid port %q", value, port)
+		}
+	}
+
+	for _, component := range strings.Split(host, ".") {
+		if !domainComponentPattern.MatchString(component) {
+			return "", "", fmt.Errorf("registry %q has an invalid host %q", value, host)
+		}
+	}
+	return strings.ToLower(host), port, nil
+}
+
+// suggestRegistry removes any scheme and whitespace, lower cases the
+// value and adds the trailing slash, then returns the normalised value if
+// it can be parsed
+func suggestRegistry(value string) (string, bool) {
+	fixed := strings.ToLower(strings.TrimSpace(value))
+	if i := strings.Index(fixed, "://"); i > -1 {
+		fixed = fixed[i+len("://"):]
+	}
+	fixed = strings.TrimSuffix(fixed, "/v1/")
+	fixed = strings.TrimRight(fixed, "/") + "/"
+
+	prefix, err := parseRegistry(fixed)
+	if err != nil {
+		return "", false
+	}
+	return prefix.String(), true
+}
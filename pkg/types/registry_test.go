@@ -0,0 +1,64 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import "testing"
+
+func Test_ParseRegistry(t *testing.T) {
+	cases := []struct {
+		value string
+		want  RegistryPrefix
+		str   string
+	}{
+		{value: "ofctest/", want: RegistryPrefix{Host: "docker.io", Namespace: "ofctest"}, str: "docker.io/ofctest/"},
+		{value: "docker.io/ofctest/", want: RegistryPrefix{Host: "docker.io", Namespace: "ofctest"}, str: "docker.io/ofctest/"},
+		{value: "index.docker.io/ofctest/", want: RegistryPrefix{Host: "docker.io", Namespace: "ofctest"}, str: "docker.io/ofctest/"},
+		{value: "registry.hub.docker.com/ofctest/", want: RegistryPrefix{Host: "docker.io", Namespace: "ofctest"}, str: "docker.io/ofctest/"},
+		{value: "ghcr.io/openfaas/team-a/", want: RegistryPrefix{Host: "ghcr.io", Namespace: "openfaas/team-a"}, str: "ghcr.io/openfaas/team-a/"},
+		{value: "Registry.Example.com:5000/team/", want: RegistryPrefix{Host: "registry.example.com", Port: "5000", Namespace: "team"}, str: "registry.example.com:5000/team/"},
+		{value: "localhost:5000/", want: RegistryPrefix{Host: "localhost", Port: "5000"}, str: "localhost:5000/"},
+		{value: "123456789012.dkr.ecr.eu-west-1.amazonaws.com/", want: RegistryPrefix{Host: "123456789012.dkr.ecr.eu-west-1.amazonaws.com"}, str: "123456789012.dkr.ecr.eu-west-1.amazonaws.com/"},
+		{value: "my-corp.jfrog.io/ofc__prod/", want: RegistryPrefix{Host: "my-corp.jfrog.io", Namespace: "ofc__prod"}, str: "my-corp.jfrog.io/ofc__prod/"},
+	}
+
+	for _, c := range cases {
+		got, err := ParseRegistry(c.value)
+		if err != nil {
+			t.Errorf("%s want no error, got: %s", c.value, err.Error())
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s want: %+v, got: %+v", c.value, c.want, got)
+		}
+		if got.String() != c.str {
+			t.Errorf("%s want normalised: %s, got: %s", c.value, c.str, got.String())
+		}
+	}
+}
+
+func Test_ParseRegistry_Invalid(t *testing.T) {
+	cases := []struct {
+		value   string
+		wantErr string
+	}{
+		{value: "", wantErr: "registry is empty, set it to the prefix for images i.e. docker.io/<user>/"},
+		{value: "docker.io/ofctest", wantErr: `registry "docker.io/ofctest" must end with "/", did you mean: "docker.io/ofctest/"?`},
+		{value: "docker.io/OfcTest/", wantErr: `registry "docker.io/OfcTest/" must be lower case, did you mean: "docker.io/ofctest/"?`},
+		{value: "https://ghcr.io/openfaas", wantErr: `registry "https://ghcr.io/openfaas" must not have a scheme, did you mean: "ghcr.io/openfaas/"?`},
+		{value: "https://index.docker.io/v1/", wantErr: `registry "https://index.docker.io/v1/" must not have a scheme`},
+		{value: "docker.io/", wantErr: `registry "docker.io/" needs a user or organisation on Docker Hub i.e. docker.io/<user>/`},
+		{value: "ghcr.io/openfaas/fn:latest/", wantErr: `registry "ghcr.io/openfaas/fn:latest/" must not have a tag`},
+		{value: "ghcr.io//openfaas/", wantErr: `registry "ghcr.io//openfaas/" has an empty path component`},
+		{value: "ghcr.io/-openfaas/", wantErr: `registry "ghcr.io/-openfaas/" has an invalid path component "-openfaas", use a-z, 0-9 and the separators ".", "_", "__" or "-" between them`},
+		{value: "registry.example.com:http/", wantErr: `registry "registry.example.com:http/" has an invalid port "http"`},
+		{value: "my_registry.example.com/team/", wantErr: `registry "my_registry.example.com/team/" has an invalid host "my_registry.example.com"`},
+	}
+
+	for _, c := range cases {
+		_, err := ParseRegistry(c.value)
+		if err == nil || err.Error() != c.wantErr {
+			t.Errorf("%s want error: %s, got: %v", c.value, c.wantErr, err)
+		}
+	}
+}
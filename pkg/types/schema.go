@@ -0,0 +1,172 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// SchemaDraft is the JSON Schema version generated by PlanSchema
+const SchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// Schema is a subset of JSON Schema, enough to describe a Plan
+type Schema struct {
+	Schema      string `json:"$schema,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type,omitempty"`
+
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+}
+
+// planEnums are the allowed values of fields, by path. List items are
+// written as "[]".
+var planEnums = map[string][]string{
+	"scm":                                   {GitHubSCM, GitLabSCM},
+	"ingress":                               {"loadbalancer", "host"},
+	"tls_config.dns_service":                {DigitalOcean, CloudDNS, Route53, Cloudflare, Azure, RFC2136, ACMEDNSProvider, Akamai},
+	"tls_config.issuer_type":                {"prod", "staging"},
+	"tls_config.challenge":                  {DNS01Challenge, HTTP01Challenge},
+	"tls_config.mode":                       {ACMETLSMode, CustomTLSMode},
+	"secrets[].literals[].generate.charset": {AlnumCharset, HexCharset, Base64Charset, UUIDCharset},
+	"secrets[].files[].generator": {
+		ECDSAP256Generator,
+		RSA2048Generator,
+		RSA4096Generator,
+		Ed25519Generator,
+		PublicKeyGenerator,
+	},
+}
+
+// PlanSchema returns a JSON Schema for the plan file, unknown fields
+// are not allowed. enums adds allowed values to the built-in ones, by
+// path, i.e. "secrets[].files[].format".
+func PlanSchema(enums map[string][]string) *Schema {
+	all := map[string][]string{}
+	for path, values := range planEnums {
+		all[path] = values
+	}
+	for path, values := range enums {
+		all[path] = values
+	}
+
+	planType := reflect.TypeOf(Plan{})
+	schema := typeSchema(planType, "", planType, all)
+	schema.Schema = SchemaDraft
+	schema.Title = "ofc-bootstrap plan"
+
+	// Merge markers are accepted on secrets, see MergePlanFiles
+	secret := schema.Properties["secrets"].Items
+	secret.Properties[RemoveMarker] = &Schema{
+		Type:        "boolean",
+		Description: "Remove a secret set by an earlier plan file",
+	}
+
+	return schema
+}
+
+func typeSchema(t reflect.Type, path string, root reflect.Type, enums map[string][]string) *Schema {
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string", Enum: enums[path]}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Slice:
+		return &Schema{
+			Type:  "array",
+			Items: typeSchema(t.Elem(), path+"[]", root, enums),
+		}
+	case reflect.Map:
+		var values *Schema
+		if t.Elem() == root {
+			values = &Schema{Ref: "#"}
+		} else {
+			values = typeSchema(t.Elem(), path+".*", root, enums)
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}
+	case reflect.Struct:
+		schema := &Schema{
+			Type:                 "object",
+			Properties:           map[string]*Schema{},
+			AdditionalProperties: false,
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if len(name) == 0 || name == "-" {
+				continue
+			}
+			schema.Properties[name] = typeSchema(field.Type, joinPath(path, name), root, enums)
+		}
+		return schema
+	}
+
+	return &Schema{}
+}
+
+var unknownFieldPattern = regexp.MustCompile(`^line (\d+): field (\S+) not found in type \S+$`)
+
+// ValidatePlanFile decodes a plan file strictly and returns an error
+// listing unknown fields and values of the wrong type, with their line
+// numbers. Merge markers accepted by MergePlanFiles are not reported.
+func ValidatePlanFile(file PlanFile) error {
+	plan := Plan{}
+	err := yaml.UnmarshalStrict(file.Data, &plan)
+	if err == nil {
+		return nil
+	}
+
+	typeErr, ok := err.(*yaml.TypeError)
+	if !ok {
+		return fmt.Errorf("unmarshal of %s gave error: %s", file.Name, err.Error())
+	}
+
+	problems := []string{}
+	for _, msg := range typeErr.Errors {
+		if strings.Contains(msg, RemoveMarker) {
+			continue
+		}
+
+		if match := unknownFieldPattern.FindStringSubmatch(msg); match != nil {
+			line, _ := strconv.Atoi(match[1])
+			problems = append(problems, fmt.Sprintf("line %d: unknown field %q", line, match[2]))
+			continue
+		}
+		problems = append(problems, msg)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return lineOf(problems[i]) < lineOf(problems[j])
+	})
+
+	return fmt.Errorf("%s is not a valid plan:\n  %s", file.Name, strings.Join(problems, "\n  "))
+}
+
+var linePattern = regexp.MustCompile(`^line (\d+):`)
+
+func lineOf(msg string) int {
+	match := linePattern.FindStringSubmatch(msg)
+	if match == nil {
+		return 0
+	}
+	line, _ := strconv.Atoi(match[1])
+	return line
+}
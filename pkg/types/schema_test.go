@@ -0,0 +1,132 @@
+// Copyright (c) OpenFaaS Author(s) 2020. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for full license information.
+
+package types
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func Test_PlanSchema_Enums(t *testing.T) {
+	schema := PlanSchema(map[string][]string{
+		"secrets[].files[].format": {"json"},
+	})
+
+	if schema.Schema != SchemaDraft {
+		t.Errorf("$schema want: %s, got: %s", SchemaDraft, schema.Schema)
+	}
+
+	got := strings.Join(schema.Properties["scm"].Enum, ",")
+	if got != "github,gitlab" {
+		t.Errorf("scm enum want: %s, got: %s", "github,gitlab", got)
+	}
+
+	got = strings.Join(schema.Properties["tls_config"].Properties["issuer_type"].Enum, ",")
+	if got != "prod,staging" {
+		t.Errorf("issuer_type enum want: %s, got: %s", "prod,staging", got)
+	}
+
+	format := schema.Properties["secrets"].Items.Properties["files"].Items.Properties["format"]
+	if len(format.Enum) != 1 || format.Enum[0] != "json" {
+		t.Errorf("format enum want: [json], got: %v", format.Enum)
+	}
+}
+
+func Test_PlanSchema_RejectsAdditionalProperties(t *testing.T) {
+	schema := PlanSchema(nil)
+
+	for _, name := range []string{"", "tls_config", "secrets[]"} {
+		s := schema
+		switch name {
+		case "tls_config":
+			s = schema.Properties["tls_config"]
+		case "secrets[]":
+			s = schema.Properties["secrets"].Items
+		}
+
+		if s.AdditionalProperties != false {
+			t.Errorf("%q additionalProperties want: false, got: %v", name, s.AdditionalProperties)
+		}
+	}
+}
+
+func Test_PlanSchema_ProfilesReferenceRoot(t *testing.T) {
+	schema := PlanSchema(nil)
+
+	out, err := json.Marshal(schema.Properties["profiles"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"type":"object","additionalProperties":{"$ref":"#"}}`
+	if string(out) != want {
+		t.Errorf("profiles want: %s, got: %s", want, string(out))
+	}
+}
+
+func Test_ValidatePlanFile_ExamplePlan(t *testing.T) {
+	data, err := ioutil.ReadFile("../../example.init.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ValidatePlanFile(PlanFile{Name: "example.init.yaml", Data: data}); err != nil {
+		t.Errorf("want no error, got: %s", err.Error())
+	}
+}
+
+func Test_ValidatePlanFile_UnknownFields(t *testing.T) {
+	file := PlanFile{Name: "init.yaml", Data: []byte(`root_domain: example.com
+tls_confg:
+  email: admin@example.com
+tls_config:
+  dns_servce: route53
+`)}
+
+	err := ValidatePlanFile(file)
+	if err == nil {
+		t.Fatalf("want error for unknown fields")
+	}
+
+	want := `init.yaml is not a valid plan:
+  line 2: unknown field "tls_confg"
+  line 5: unknown field "dns_servce"`
+	if err.Error() != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, err.Error())
+	}
+}
+
+func Test_ValidatePlanFile_AllowsMergeMarkers(t *testing.T) {
+	file := PlanFile{Name: "override.yaml", Data: []byte(`tls: $remove
+features:
+- $replace
+- auth
+secrets:
+- name: s3-secret-key
+  $remove: true
+`)}
+
+	if err := ValidatePlanFile(file); err != nil {
+		t.Errorf("want no error, got: %s", err.Error())
+	}
+}
+
+func Test_MergePlanFiles_RejectsUnknownFieldInProfile(t *testing.T) {
+	file := PlanFile{Name: "init.yaml", Data: []byte(`root_domain: example.com
+profiles:
+  staging:
+    enable_ouath: true
+`)}
+
+	_, err := MergePlanFiles([]PlanFile{file}, "staging")
+	if err == nil {
+		t.Fatalf("want error for unknown field")
+	}
+
+	if !strings.Contains(err.Error(), `line 4: unknown field "enable_ouath"`) {
+		t.Errorf("want line 4 in error, got: %s", err.Error())
+	}
+}
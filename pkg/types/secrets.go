@@ -1,6 +1,9 @@
 package types
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"os"
@@ -9,6 +12,11 @@ import (
 	"github.com/sethvargo/go-password/password"
 )
 
+const (
+	defaultSecretLength = 25
+	defaultSecretDigits = 10
+)
+
 func BuildSecretTask(kvn KeyValueNamespaceTuple) execute.ExecTask {
 	task := execute.ExecTask{
 		Command:     "kubectl",
@@ -23,7 +31,7 @@ func BuildSecretTask(kvn KeyValueNamespaceTuple) execute.ExecTask {
 	for _, key := range kvn.Literals {
 		secretValue := key.Value
 		if len(secretValue) == 0 {
-			val, err := generateSecret()
+			val, err := generateSecret(key.Generate)
 			if err != nil {
 				fmt.Fprintln(os.Stderr, err)
 				os.Exit(1)
@@ -62,10 +70,95 @@ func BuildSecretTask(kvn KeyValueNamespaceTuple) execute.ExecTask {
 	return task
 }
 
-func generateSecret() (string, error) {
-	pass, err := password.Generate(25, 10, 0, false, true)
+// Validate checks that the policy can be used to generate a value
+func (g GeneratePolicy) Validate() error {
+	digits := 0
+	if g.Digits != nil {
+		digits = *g.Digits
+	}
+
+	if g.Length < 0 || digits < 0 || g.Symbols < 0 {
+		return fmt.Errorf("length, digits and symbols must not be negative")
+	}
+
+	switch g.Charset {
+	case "", AlnumCharset:
+		length := g.Length
+		if length == 0 {
+			length = defaultSecretLength
+		}
+		if digits+g.Symbols > length {
+			return fmt.Errorf("digits (%d) and symbols (%d) exceed the length (%d)", digits, g.Symbols, length)
+		}
+	case HexCharset, Base64Charset:
+		if digits > 0 || g.Symbols > 0 {
+			return fmt.Errorf("digits and symbols are only supported by the %s charset", AlnumCharset)
+		}
+	case UUIDCharset:
+		if g.Length > 0 || digits > 0 || g.Symbols > 0 {
+			return fmt.Errorf("length, digits and symbols are not supported by the %s charset", UUIDCharset)
+		}
+	default:
+		return fmt.Errorf("unsupported charset: %q, use one of: %s, %s, %s or %s",
+			g.Charset, AlnumCharset, HexCharset, Base64Charset, UUIDCharset)
+	}
+
+	return nil
+}
+
+func generateSecret(policy GeneratePolicy) (string, error) {
+	if err := policy.Validate(); err != nil {
+		return "", err
+	}
+
+	length := policy.Length
+	if length == 0 {
+		length = defaultSecretLength
+	}
+
+	switch policy.Charset {
+	case HexCharset:
+		buf, err := randomBytes((length + 1) / 2)
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(buf)[:length], nil
+	case Base64Charset:
+		buf, err := randomBytes((length*3 + 3) / 4)
+		if err != nil {
+			return "", err
+		}
+		return base64.RawStdEncoding.EncodeToString(buf)[:length], nil
+	case UUIDCharset:
+		buf, err := randomBytes(16)
+		if err != nil {
+			return "", err
+		}
+		buf[6] = (buf[6] & 0x0f) | 0x40
+		buf[8] = (buf[8] & 0x3f) | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:]), nil
+	}
+
+	// Unless set, the default digits are included in as much of the
+	// length as is left after the symbols
+	digits := defaultSecretDigits
+	if policy.Digits != nil {
+		digits = *policy.Digits
+	} else if digits > length-policy.Symbols {
+		digits = length - policy.Symbols
+	}
+
+	pass, err := password.Generate(length, digits, policy.Symbols, false, true)
 	if err != nil {
 		return "", err
 	}
 	return pass, nil
 }
+
+func randomBytes(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
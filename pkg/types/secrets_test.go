@@ -0,0 +1,157 @@
+package types
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func Test_generateSecret_DefaultPolicy(t *testing.T) {
+	got, err := generateSecret(GeneratePolicy{})
+	if err != nil {
+		t.Fatalf("want no error, got: %s", err.Error())
+	}
+
+	if len(got) != 25 {
+		t.Errorf("want length: %d, got: %d", 25, len(got))
+	}
+
+	digits := regexp.MustCompile("[0-9]").FindAllString(got, -1)
+	if len(digits) != 10 {
+		t.Errorf("want %d digits, got: %d", 10, len(digits))
+	}
+}
+
+func Test_generateSecret_PartialPolicy(t *testing.T) {
+	tests := []struct {
+		title      string
+		policy     GeneratePolicy
+		wantLength int
+		wantDigits int
+	}{
+		{title: "length only", policy: GeneratePolicy{Length: 40}, wantLength: 40, wantDigits: 10},
+		{title: "charset only", policy: GeneratePolicy{Charset: AlnumCharset}, wantLength: 25, wantDigits: 10},
+		{title: "symbols only", policy: GeneratePolicy{Symbols: 4}, wantLength: 25, wantDigits: 10},
+		{title: "digits capped to the length", policy: GeneratePolicy{Length: 8, Symbols: 2}, wantLength: 8, wantDigits: 6},
+		{title: "explicit digits", policy: GeneratePolicy{Length: 30, Digits: intPtr(3)}, wantLength: 30, wantDigits: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			got, err := generateSecret(test.policy)
+			if err != nil {
+				t.Fatalf("want no error, got: %s", err.Error())
+			}
+			if len(got) != test.wantLength {
+				t.Errorf("want length: %d, got: %d", test.wantLength, len(got))
+			}
+			digits := regexp.MustCompile("[0-9]").FindAllString(got, -1)
+			if len(digits) != test.wantDigits {
+				t.Errorf("want %d digits, got: %d in %q", test.wantDigits, len(digits), got)
+			}
+		})
+	}
+}
+
+func Test_generateSecret_Charsets(t *testing.T) {
+	tests := []struct {
+		title  string
+		policy GeneratePolicy
+		match  string
+	}{
+		{
+			title:  "alnum with length and no digits",
+			policy: GeneratePolicy{Length: 40, Digits: intPtr(0)},
+			match:  "^[a-zA-Z]{40}$",
+		},
+		{
+			title:  "hex with odd length",
+			policy: GeneratePolicy{Charset: HexCharset, Length: 33},
+			match:  "^[0-9a-f]{33}$",
+		},
+		{
+			title:  "base64 with default length",
+			policy: GeneratePolicy{Charset: Base64Charset},
+			match:  "^[a-zA-Z0-9+/]{25}$",
+		},
+		{
+			title:  "uuid",
+			policy: GeneratePolicy{Charset: UUIDCharset},
+			match:  "^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			got, err := generateSecret(test.policy)
+			if err != nil {
+				t.Fatalf("want no error, got: %s", err.Error())
+			}
+			if !regexp.MustCompile(test.match).MatchString(got) {
+				t.Errorf("want value matching %q, got: %q", test.match, got)
+			}
+		})
+	}
+}
+
+func TestGeneratePolicy_Validate(t *testing.T) {
+	tests := []struct {
+		title   string
+		policy  GeneratePolicy
+		wantErr string
+	}{
+		{
+			title:  "empty policy is valid",
+			policy: GeneratePolicy{},
+		},
+		{
+			title:  "alnum with symbols is valid",
+			policy: GeneratePolicy{Charset: AlnumCharset, Length: 32, Digits: intPtr(8), Symbols: 4},
+		},
+		{
+			title:   "unknown charset",
+			policy:  GeneratePolicy{Charset: "ascii"},
+			wantErr: "unsupported charset",
+		},
+		{
+			title:   "digits and symbols exceed length",
+			policy:  GeneratePolicy{Length: 10, Digits: intPtr(8), Symbols: 4},
+			wantErr: "exceed the length",
+		},
+		{
+			title:   "symbols with hex",
+			policy:  GeneratePolicy{Charset: HexCharset, Symbols: 2},
+			wantErr: "only supported by the alnum charset",
+		},
+		{
+			title:   "length with uuid",
+			policy:  GeneratePolicy{Charset: UUIDCharset, Length: 12},
+			wantErr: "not supported by the uuid charset",
+		},
+		{
+			title:   "negative length",
+			policy:  GeneratePolicy{Length: -1},
+			wantErr: "must not be negative",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			err := test.policy.Validate()
+			if len(test.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("want no error, got: %s", err.Error())
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("want error containing: %q, got: %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -24,6 +24,14 @@ const (
 	Route53DNS = "route53_dns01"
 	// CloudflareDNS filter enables the creation of secrets for Cloudflare DNS when TLS is enabled
 	CloudflareDNS = "cloudflare_dns01"
+	// AzureDNS filter enables the creation of secrets for Azure DNS when TLS is enabled
+	AzureDNS = "azure_dns01"
+	// RFC2136DNS filter enables the creation of secrets for RFC2136 (i.e. BIND with TSIG) when TLS is enabled
+	RFC2136DNS = "rfc2136_dns01"
+	// ACMEDNS filter enables the creation of secrets for acme-dns when TLS is enabled
+	ACMEDNS = "acmedns_dns01"
+	// AkamaiDNS filter enables the creation of secrets for Akamai Edge DNS when TLS is enabled
+	AkamaiDNS = "akamai_dns01"
 
 	// CloudDNS is the dns_service field in yaml file for Google Cloud Platform
 	CloudDNS = "clouddns"
@@ -33,6 +41,38 @@ const (
 	Route53 = "route53"
 	// Cloudflare for dns_service
 	Cloudflare = "cloudflare"
+	// Azure is the dns_service field in yaml file for Azure DNS
+	Azure = "azuredns"
+	// RFC2136 is the dns_service field in yaml file for RFC2136 nameservers
+	RFC2136 = "rfc2136"
+	// ACMEDNSProvider is the dns_service field in yaml file for acme-dns
+	ACMEDNSProvider = "acmedns"
+	// Akamai is the dns_service field in yaml file for Akamai Edge DNS
+	Akamai = "akamai"
+
+	// HTTP01Feature is enabled instead of a DNS feature when certificates
+	// are issued with the HTTP01 challenge
+	HTTP01Feature = "http01"
+
+	// CustomTLSFeature is enabled instead of a DNS feature when a
+	// certificate is brought in with tls_config.mode: custom
+	CustomTLSFeature = "custom_tls"
+
+	// ACMETLSMode issues certificates with cert-manager
+	ACMETLSMode = "acme"
+	// CustomTLSMode uses the certificate in cert_file and key_file,
+	// cert-manager is not installed
+	CustomTLSMode = "custom"
+
+	// DNS01Challenge issues a wildcard certificate through a DNS provider
+	DNS01Challenge = "dns01"
+	// HTTP01Challenge issues a certificate for each host through the
+	// ingress controller
+	HTTP01Challenge = "http01"
+
+	// DefaultIngressClass is the class of the ingress controller
+	// installed by ofc-bootstrap
+	DefaultIngressClass = "nginx"
 
 	// GitLabSCM repository manager name as displayed in the init.yaml file
 	GitLabSCM = "gitlab"
@@ -41,6 +81,26 @@ const (
 
 	// ECRFeature enable ECR
 	ECRFeature = "ecr"
+
+	// AlnumCharset generates letters, digits and optional symbols
+	AlnumCharset = "alnum"
+	// HexCharset generates lower-case hexadecimal characters
+	HexCharset = "hex"
+	// Base64Charset generates standard base64 characters without padding
+	Base64Charset = "base64"
+	// UUIDCharset generates a random (version 4) UUID
+	UUIDCharset = "uuid"
+
+	// ECDSAP256Generator generates an ECDSA private key on the P-256 curve
+	ECDSAP256Generator = "ecdsa-p256-private"
+	// RSA2048Generator generates a 2048-bit RSA private key
+	RSA2048Generator = "rsa-2048-private"
+	// RSA4096Generator generates a 4096-bit RSA private key
+	RSA4096Generator = "rsa-4096-private"
+	// Ed25519Generator generates an Ed25519 private key
+	Ed25519Generator = "ed25519-private"
+	// PublicKeyGenerator derives a public key from the secret named in public_key_of
+	PublicKeyGenerator = "public-key"
 )
 
 type Plan struct {
@@ -71,6 +131,10 @@ type Plan struct {
 	CustomersSecret      bool                     `yaml:"customers_secret,omitempty"`
 	IngressOperator      bool                     `yaml:"ingress_operator,omitempty"`
 	OpenFaaSOperator     bool                     `yaml:"openfaas_operator,omitempty"`
+
+	// Profiles are named overlays which are merged into the plan
+	// when selected with --profile
+	Profiles map[string]Plan `yaml:"profiles,omitempty"`
 }
 
 // Deployment is the deployment section of YAML concerning
@@ -92,6 +156,28 @@ func (d Deployment) FormatCustomTemplates() string {
 type KeyValueTuple struct {
 	Name  string `yaml:"name,omitempty"`
 	Value string `yaml:"value,omitempty"`
+
+	// Generate is the policy used to generate Value when it
+	// is left empty
+	Generate GeneratePolicy `yaml:"generate,omitempty"`
+}
+
+// GeneratePolicy controls the format of a generated literal. When
+// omitted, 25 alphanumeric characters including 10 digits are used.
+type GeneratePolicy struct {
+	// Length of the generated value in characters, defaults to 25
+	Length int `yaml:"length,omitempty"`
+
+	// Charset is one of alnum (default), hex, base64 or uuid
+	Charset string `yaml:"charset,omitempty"`
+
+	// Digits is the number of digits to include for the alnum charset,
+	// when unset 10 are included, or as many as fit in the length. Set
+	// it to 0 for no digits.
+	Digits *int `yaml:"digits,omitempty"`
+
+	// Symbols is the number of symbols to include for the alnum charset
+	Symbols int `yaml:"symbols,omitempty"`
 }
 
 type FileSecret struct {
@@ -101,6 +187,18 @@ type FileSecret struct {
 	// ValueCommand is a command to execute to generate
 	// a secret file specified in ValueFrom
 	ValueCommand string `yaml:"value_command,omitempty"`
+
+	// Generator creates the file specified in ValueFrom without
+	// external tools, if it does not exist already
+	Generator string `yaml:"generator,omitempty"`
+
+	// PublicKeyOf is the name of the secret holding the private key
+	// when Generator is set to public-key
+	PublicKeyOf string `yaml:"public_key_of,omitempty"`
+
+	// Format is the expected content of the file, which is checked
+	// before any secrets are created i.e. pem-rsa or json
+	Format string `yaml:"format,omitempty"`
 }
 
 // ExpandValueFrom expands ~ to the home directory of the current user
@@ -118,6 +216,29 @@ type KeyValueNamespaceTuple struct {
 	Files     []FileSecret    `yaml:"files,omitempty"`
 	Type      string          `yaml:"type,omitempty"`
 	Filters   []string        `yaml:"filters,omitempty"`
+
+	// Existing secrets are managed outside of ofc-bootstrap, they are
+	// never created, but must be present with the keys named in
+	// Literals and Files
+	Existing bool `yaml:"existing,omitempty"`
+
+	// Labels to add to the secret in addition to the standard labels
+	Labels map[string]string `yaml:"labels,omitempty"`
+
+	// Annotations to add to the secret
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// Keys returns the names of the literals and files of the secret
+func (kvn KeyValueNamespaceTuple) Keys() []string {
+	keys := []string{}
+	for _, literal := range kvn.Literals {
+		keys = append(keys, literal.Name)
+	}
+	for _, file := range kvn.Files {
+		keys = append(keys, file.Name)
+	}
+	return keys
 }
 
 type Github struct {
@@ -153,8 +274,125 @@ type TLSConfig struct {
 	IssuerType  string `yaml:"issuer_type,omitempty"`
 	Region      string `yaml:"region,omitempty"`
 	AccessKeyID string `yaml:"access_key_id,omitempty"`
+
+	// Azure DNS, the client secret of the service principal is in the
+	// azuredns-config secret
+	ClientID       string `yaml:"client_id,omitempty"`
+	SubscriptionID string `yaml:"subscription_id,omitempty"`
+	TenantID       string `yaml:"tenant_id,omitempty"`
+	ResourceGroup  string `yaml:"resource_group,omitempty"`
+	HostedZone     string `yaml:"hosted_zone,omitempty"`
+
+	// RFC2136, the TSIG secret is in the rfc2136-tsig-secret secret
+	Nameserver    string `yaml:"nameserver,omitempty"`
+	TSIGKeyName   string `yaml:"tsig_key_name,omitempty"`
+	TSIGAlgorithm string `yaml:"tsig_algorithm,omitempty"`
+
+	// ACMEDNSHost is the URL of the acme-dns server, the account is in
+	// the acme-dns secret
+	ACMEDNSHost string `yaml:"acme_dns_host,omitempty"`
+
+	// ServiceConsumerDomain is the Akamai API host, the client token,
+	// client secret and access token are in the akamai-dns secret
+	ServiceConsumerDomain string `yaml:"service_consumer_domain,omitempty"`
+
+	// Mode is acme (default) or custom, the latter uses the wildcard
+	// certificate in CertFile and KeyFile instead of cert-manager
+	Mode     string `yaml:"mode,omitempty"`
+	CertFile string `yaml:"cert_file,omitempty"`
+	KeyFile  string `yaml:"key_file,omitempty"`
+	// CAFile is the optional CA bundle which issued CertFile
+	CAFile string `yaml:"ca_file,omitempty"`
+
+	// Challenge is dns01 (default) or http01
+	Challenge string `yaml:"challenge,omitempty"`
+	// IngressClass solves http01 challenges, defaults to nginx
+	IngressClass string `yaml:"ingress_class,omitempty"`
+	// Subdomains of root_domain which need a certificate with http01,
+	// in addition to system and gateway, i.e. the user or org of each
+	// customer
+	Subdomains []string `yaml:"subdomains,omitempty"`
+}
+
+// ChallengeType is the challenge used to issue certificates
+func (t TLSConfig) ChallengeType() string {
+	if len(t.Challenge) == 0 {
+		return DNS01Challenge
+	}
+	return t.Challenge
+}
+
+// TLSMode is how certificates are provided
+func (t TLSConfig) TLSMode() string {
+	if len(t.Mode) == 0 {
+		return ACMETLSMode
+	}
+	return t.Mode
+}
+
+// DefaultTSIGAlgorithm is used for RFC2136 when tsig_algorithm is empty
+const DefaultTSIGAlgorithm = "HMACSHA512"
+
+// TSIGAlgorithmName is the algorithm of the RFC2136 TSIG key
+func (t TLSConfig) TSIGAlgorithmName() string {
+	if len(t.TSIGAlgorithm) == 0 {
+		return DefaultTSIGAlgorithm
+	}
+	return t.TSIGAlgorithm
+}
+
+// IngressClassName is the ingress class which solves http01 challenges
+func (t TLSConfig) IngressClassName() string {
+	if len(t.IngressClass) == 0 {
+		return DefaultIngressClass
+	}
+	return t.IngressClass
+}
+
+// TLSHosts are the hosts which get their own certificate with the http01
+// challenge, the auth.system host always has its own certificate
+func (p Plan) TLSHosts() []string {
+	hosts := []string{"system." + p.RootDomain, "gateway." + p.RootDomain}
+	for _, subdomain := range p.TLSConfig.Subdomains {
+		hosts = append(hosts, strings.ToLower(subdomain)+"."+p.RootDomain)
+	}
+	return hosts
+}
+
+// UsesCertManager is true when certificates are issued by cert-manager
+func (p Plan) UsesCertManager() bool {
+	return p.TLS && p.TLSConfig.TLSMode() == ACMETLSMode
+}
+
+// CustomTLSSecrets are the TLS secrets referenced by the wildcard and
+// auth ingress records, created from the certificate in tls_config
+func (p Plan) CustomTLSSecrets() []KeyValueNamespaceTuple {
+	files := []FileSecret{
+		{Name: "tls.crt", ValueFrom: p.TLSConfig.CertFile},
+		{Name: "tls.key", ValueFrom: p.TLSConfig.KeyFile},
+	}
+	if len(p.TLSConfig.CAFile) > 0 {
+		files = append(files, FileSecret{Name: "ca.crt", ValueFrom: p.TLSConfig.CAFile})
+	}
+
+	secrets := []KeyValueNamespaceTuple{}
+	for _, name := range []string{"wildcard-" + p.RootDomain + "-cert", "auth-system-" + p.RootDomain + "-cert"} {
+		secrets = append(secrets, KeyValueNamespaceTuple{
+			Name:      name,
+			Namespace: "openfaas",
+			Type:      "kubernetes.io/tls",
+			Files:     files,
+			Filters:   []string{CustomTLSFeature},
+		})
+	}
+	return secrets
 }
 
 type ECRConfig struct {
 	ECRRegion string `yaml:"ecr_region,omitempty"`
+
+	// Profile of the AWS credentials file to extract into the ECR
+	// secrets, as their default profile. The whole file is used when
+	// it is empty.
+	Profile string `yaml:"profile,omitempty"`
 }
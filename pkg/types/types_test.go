@@ -2,6 +2,7 @@ package types
 
 import (
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -17,3 +18,46 @@ func TestFileSecret_ExpandValueFrom(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestKeyValueNamespaceTuple_Keys(t *testing.T) {
+	kvn := KeyValueNamespaceTuple{
+		Literals: []KeyValueTuple{{Name: "basic-auth-user"}, {Name: "basic-auth-password"}},
+		Files:    []FileSecret{{Name: "config.json"}},
+	}
+
+	want := []string{"basic-auth-user", "basic-auth-password", "config.json"}
+	got := kvn.Keys()
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("error want: %v, got %v", want, got)
+		t.Fail()
+	}
+}
+
+func TestPlan_CustomTLSSecrets(t *testing.T) {
+	plan := Plan{
+		RootDomain: "example.com",
+		TLS:        true,
+		TLSConfig:  TLSConfig{Mode: CustomTLSMode, CertFile: "~/tls/wildcard.crt", KeyFile: "~/tls/wildcard.key", CAFile: "~/tls/ca.crt"},
+	}
+
+	if plan.UsesCertManager() {
+		t.Errorf("want no cert-manager with a custom certificate")
+	}
+
+	secrets := plan.CustomTLSSecrets()
+	names := []string{}
+	for _, secret := range secrets {
+		names = append(names, secret.Name)
+		if secret.Namespace != "openfaas" || secret.Type != "kubernetes.io/tls" {
+			t.Errorf("secret %s want type kubernetes.io/tls in openfaas, got: %s in %s", secret.Name, secret.Type, secret.Namespace)
+		}
+		if got := strings.Join(secret.Keys(), ","); got != "tls.crt,tls.key,ca.crt" {
+			t.Errorf("secret %s want keys tls.crt,tls.key,ca.crt, got: %s", secret.Name, got)
+		}
+	}
+
+	want := "wildcard-example.com-cert,auth-system-example.com-cert"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("want secrets: %s, got: %s", want, got)
+	}
+}
@@ -0,0 +1,100 @@
+package validators
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// ValidateCertificate checks that the certificate in certFile matches the
+// key in keyFile, is valid at now and covers each of names. Names starting
+// with *. must be in the certificate as a wildcard. When caFile is given,
+// the certificate must chain to one of its certificates.
+func ValidateCertificate(certFile, keyFile, caFile string, names []string, now time.Time) error {
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return err
+	}
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return err
+	}
+
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("certificate %s and key %s: %s", certFile, keyFile, err.Error())
+	}
+
+	chain := []*x509.Certificate{}
+	for _, der := range pair.Certificate {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return fmt.Errorf("certificate %s: %s", certFile, err.Error())
+		}
+		chain = append(chain, cert)
+	}
+	leaf := chain[0]
+
+	if now.Before(leaf.NotBefore) {
+		return fmt.Errorf("certificate %s is not valid until %s", certFile, leaf.NotBefore.Format(time.RFC3339))
+	}
+	if now.After(leaf.NotAfter) {
+		return fmt.Errorf("certificate %s expired on %s", certFile, leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	for _, name := range names {
+		if !certificateCovers(leaf, name) {
+			return fmt.Errorf("certificate %s does not cover %s, it has: %s", certFile, name, strings.Join(leaf.DNSNames, ", "))
+		}
+	}
+
+	if len(caFile) == 0 {
+		return nil
+	}
+
+	caPEM, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		return fmt.Errorf("CA bundle %s has no PEM certificates", caFile)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	}); err != nil {
+		return fmt.Errorf("certificate %s was not issued by the CA in %s: %s", certFile, caFile, err.Error())
+	}
+
+	return nil
+}
+
+// certificateCovers matches name against the DNS names of cert, a wildcard
+// only matches the left-most label
+func certificateCovers(cert *x509.Certificate, name string) bool {
+	name = strings.ToLower(name)
+	for _, dnsName := range cert.DNSNames {
+		dnsName = strings.ToLower(dnsName)
+		if dnsName == name {
+			return true
+		}
+		if strings.HasPrefix(name, "*.") || !strings.HasPrefix(dnsName, "*.") {
+			continue
+		}
+		if i := strings.Index(name, "."); i > 0 && name[i:] == dnsName[1:] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package validators
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var certNow = time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, dnsNames []string, notAfter time.Time, parent *testCert) testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "ofc-test"},
+		DNSNames:     dnsNames,
+		NotBefore:    certNow.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return testCert{cert: cert, key: key, der: der}
+}
+
+func writeTestCert(t *testing.T, dir, name string, c testCert) (string, string) {
+	certFile := filepath.Join(dir, name+".crt")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600)
+
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, name+".key")
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile
+}
+
+func Test_ValidateCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certificate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, nil, certNow.Add(24*time.Hour), nil)
+	caFile, _ := writeTestCert(t, dir, "ca", ca)
+	otherCAFile, _ := writeTestCert(t, dir, "other-ca", newTestCert(t, nil, certNow.Add(24*time.Hour), nil))
+
+	wildcard := newTestCert(t, []string{"*.example.com", "*.system.example.com"}, certNow.Add(24*time.Hour), &ca)
+	wildcardCert, wildcardKey := writeTestCert(t, dir, "wildcard", wildcard)
+
+	expiredCert, expiredKey := writeTestCert(t, dir, "expired", newTestCert(t, []string{"*.example.com"}, certNow.Add(-time.Hour), &ca))
+	rootCert, rootKey := writeTestCert(t, dir, "root", newTestCert(t, []string{"*.example.com"}, certNow.Add(24*time.Hour), &ca))
+	hostCert, hostKey := writeTestCert(t, dir, "host", newTestCert(t, []string{"system.example.com"}, certNow.Add(24*time.Hour), &ca))
+
+	cases := []struct {
+		title    string
+		certFile string
+		keyFile  string
+		caFile   string
+		names    []string
+		wantErr  string
+	}{
+		{
+			title:    "wildcard and auth host are covered",
+			certFile: wildcardCert, keyFile: wildcardKey, caFile: caFile,
+			names: []string{"*.example.com", "auth.system.example.com"},
+		},
+		{
+			title:    "expired certificate",
+			certFile: expiredCert, keyFile: expiredKey,
+			names:   []string{"*.example.com"},
+			wantErr: "expired on",
+		},
+		{
+			title:    "certificate for a host does not cover the wildcard",
+			certFile: hostCert, keyFile: hostKey,
+			names:   []string{"*.example.com"},
+			wantErr: "does not cover *.example.com",
+		},
+		{
+			title:    "wildcard does not cover a nested host",
+			certFile: rootCert, keyFile: rootKey,
+			names:   []string{"*.example.com", "auth.system.example.com"},
+			wantErr: "does not cover auth.system.example.com",
+		},
+		{
+			title:    "key does not match the certificate",
+			certFile: wildcardCert, keyFile: hostKey,
+			names:   []string{"*.example.com"},
+			wantErr: "private key does not match public key",
+		},
+		{
+			title:    "certificate from another CA",
+			certFile: wildcardCert, keyFile: wildcardKey, caFile: otherCAFile,
+			names:   []string{"*.example.com"},
+			wantErr: "was not issued by the CA",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.title, func(t *testing.T) {
+			err := ValidateCertificate(c.certFile, c.keyFile, c.caFile, c.names, certNow)
+			if len(c.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("want no error, got: %s", err.Error())
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Errorf("want error containing %q, got: %v", c.wantErr, err)
+			}
+		})
+	}
+}
+
+func Test_certificateCovers(t *testing.T) {
+	cert := &x509.Certificate{DNSNames: []string{"*.Example.com", "auth.system.example.com"}}
+
+	for name, want := range map[string]bool{
+		"*.example.com":           true,
+		"gateway.example.com":     true,
+		"auth.system.example.com": true,
+		"a.system.example.com":    false,
+		"*.system.example.com":    false,
+		"example.com":             false,
+	} {
+		if got := certificateCovers(cert, name); got != want {
+			t.Errorf("%s want: %t, got: %t", name, want, got)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package validators
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	execute "github.com/alexellis/go-execute/pkg/v1"
+)
+
+// identityToken is given as the username by a credential helper when the
+// secret is an identity token rather than a password
+const identityToken = "<token>"
+
+// Credentials are a username and password for a registry server
+type Credentials struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+// CredentialHelper runs an action of the docker-credential-<helper>
+// protocol, writing input to its stdin and returning its stdout
+type CredentialHelper func(helper, action, input string) (string, error)
+
+// RunCredentialHelper runs docker-credential-<helper> from the PATH
+func RunCredentialHelper(helper, action, input string) (string, error) {
+	task := execute.ExecTask{
+		Command:     "docker-credential-" + helper,
+		Args:        []string{action},
+		Stdin:       strings.NewReader(input),
+		StreamStdio: false,
+	}
+
+	res, err := task.Execute()
+	if err != nil {
+		return "", fmt.Errorf("unable to run docker-credential-%s: %s", helper, err.Error())
+	}
+	if res.ExitCode != 0 {
+		return "", fmt.Errorf("docker-credential-%s %s gave error: %s", helper, action, strings.TrimSpace(res.Stdout+res.Stderr))
+	}
+	return res.Stdout, nil
+}
+
+// DockerConfigPath is the config.json of the Docker CLI, in $DOCKER_CONFIG
+// or ~/.docker
+func DockerConfigPath() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); len(dir) > 0 {
+		return filepath.Join(dir, "config.json")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// ServerFor is the server which the Docker CLI logs into for a
+// normalised registry host
+func ServerFor(host string) string {
+	if host == DockerHubHost {
+		return DockerHubServer
+	}
+	return host
+}
+
+// ResolveCredentials finds the username and password for the normalised
+// registry host in a Docker config.json file, asking the credential helper
+// for them when they are held by credHelpers or credsStore
+func ResolveCredentials(host string, configFileBytes []byte, run CredentialHelper) (*Credentials, error) {
+	registryData, err := unmarshalRegistryConfig(configFileBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	registryAuth, err := lookupRegistryAuth(registryData, host)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(registryAuth.Helper) == 0 {
+		decoded, _ := base64.StdEncoding.DecodeString(registryAuth.Auth)
+		parts := strings.SplitN(strings.TrimSpace(string(decoded)), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("auths entry %q is not valid (auth must be username:password)", registryAuth.Key)
+		}
+		return &Credentials{ServerURL: registryAuth.Key, Username: parts[0], Secret: parts[1]}, nil
+	}
+
+	server := helperServer(registryAuth, run)
+	out, err := run(registryAuth.Helper, "get", server)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := Credentials{}
+	if err := json.Unmarshal([]byte(out), &credentials); err != nil {
+		return nil, fmt.Errorf("unable to parse the response of docker-credential-%s: %s", registryAuth.Helper, err.Error())
+	}
+	if len(credentials.ServerURL) == 0 {
+		credentials.ServerURL = server
+	}
+
+	if credentials.Username == identityToken {
+		return nil, fmt.Errorf("docker-credential-%s holds an identity token for %s, which cannot be used in an auths file. Use ofc-bootstrap registry-login with a username and password or access token instead",
+			registryAuth.Helper, registryAuth.Host)
+	}
+	if len(credentials.Username) == 0 || len(credentials.Secret) == 0 {
+		return nil, fmt.Errorf("docker-credential-%s gave no credentials for %s", registryAuth.Helper, server)
+	}
+	return &credentials, nil
+}
+
+// helperServer finds the server under which the helper holds credentials
+// for the registry from its list action, since docker login may have
+// been given a scheme or path. The Docker CLI's key is used otherwise.
+func helperServer(registryAuth *RegistryAuth, run CredentialHelper) string {
+	out, err := run(registryAuth.Helper, "list", "")
+	if err == nil {
+		servers := map[string]string{}
+		if json.Unmarshal([]byte(out), &servers) == nil {
+			for _, server := range sortedKeys(servers) {
+				if ServerHost(server) == registryAuth.Host {
+					return server
+				}
+			}
+		}
+	}
+	return ServerFor(registryAuth.Host)
+}
@@ -0,0 +1,113 @@
+package validators
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// fakeHelper holds credentials by server, like docker-credential-desktop
+func fakeHelper(t *testing.T, wantHelper string, servers map[string]string) CredentialHelper {
+	return func(helper, action, input string) (string, error) {
+		if helper != wantHelper {
+			t.Errorf("helper want: %s, got: %s", wantHelper, helper)
+		}
+
+		switch action {
+		case "list":
+			list := map[string]string{}
+			for server := range servers {
+				list[server] = "user"
+			}
+			out, _ := json.Marshal(list)
+			return string(out), nil
+		case "get":
+			secret, ok := servers[input]
+			if !ok {
+				return "", fmt.Errorf("credentials not found in native keychain")
+			}
+			return fmt.Sprintf(`{"ServerURL": %q, "Username": "user", "Secret": %q}`, input, secret), nil
+		}
+		return "", fmt.Errorf("unknown action: %s", action)
+	}
+}
+
+func Test_ResolveCredentials_CredsStore(t *testing.T) {
+	file := []byte(`{"auths": {"https://index.docker.io/v1/": {}}, "credsStore": "desktop"}`)
+	run := fakeHelper(t, "desktop", map[string]string{"https://index.docker.io/v1/": "s3cret"})
+
+	credentials, err := ResolveCredentials("docker.io", file, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials.Username != "user" || credentials.Secret != "s3cret" {
+		t.Errorf("want user s3cret, got: %s %s", credentials.Username, credentials.Secret)
+	}
+}
+
+func Test_ResolveCredentials_CredsStoreShadowsStaleAuths(t *testing.T) {
+	file := []byte(`{"auths": {"https://index.docker.io/v1/": {"auth": "dXNlcjpzdGFsZQ=="}}, "credsStore": "desktop"}`)
+	run := fakeHelper(t, "desktop", map[string]string{"https://index.docker.io/v1/": "s3cret"})
+
+	credentials, err := ResolveCredentials("docker.io", file, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials.Secret != "s3cret" {
+		t.Errorf("want the secret from the credsStore, got: %s", credentials.Secret)
+	}
+}
+
+func Test_ResolveCredentials_CredHelpersListedServer(t *testing.T) {
+	file := []byte(`{"auths": {}, "credsStore": "desktop", "credHelpers": {"ghcr.io": "osxkeychain"}}`)
+	run := fakeHelper(t, "osxkeychain", map[string]string{"https://ghcr.io": "token"})
+
+	credentials, err := ResolveCredentials("ghcr.io", file, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials.ServerURL != "https://ghcr.io" || credentials.Secret != "token" {
+		t.Errorf("want https://ghcr.io token, got: %s %s", credentials.ServerURL, credentials.Secret)
+	}
+}
+
+func Test_ResolveCredentials_Auths(t *testing.T) {
+	file := []byte(`{"auths": {"quay.io": {"auth": "cm9ib3Q6cGFzcw=="}}}`)
+	run := func(helper, action, input string) (string, error) {
+		t.Errorf("want no helper to be run, got: %s %s", helper, action)
+		return "", nil
+	}
+
+	credentials, err := ResolveCredentials("quay.io", file, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials.Username != "robot" || credentials.Secret != "pass" {
+		t.Errorf("want robot pass, got: %s %s", credentials.Username, credentials.Secret)
+	}
+}
+
+func Test_ResolveCredentials_IdentityToken(t *testing.T) {
+	file := []byte(`{"auths": {}, "credsStore": "desktop"}`)
+	run := func(helper, action, input string) (string, error) {
+		if action == "list" {
+			return "", fmt.Errorf("list is not supported")
+		}
+		return `{"ServerURL": "myregistry.azurecr.io", "Username": "<token>", "Secret": "refresh"}`, nil
+	}
+
+	_, err := ResolveCredentials("myregistry.azurecr.io", file, run)
+	want := "docker-credential-desktop holds an identity token for myregistry.azurecr.io, which cannot be used in an auths file. Use ofc-bootstrap registry-login with a username and password or access token instead"
+	if err == nil || err.Error() != want {
+		t.Errorf("want error: %s, got: %v", want, err)
+	}
+}
+
+func Test_ResolveCredentials_NotFound(t *testing.T) {
+	file := []byte(`{"auths": {}}`)
+
+	_, err := ResolveCredentials("ghcr.io", file, nil)
+	if err == nil {
+		t.Errorf("want error for a registry with no entry or helper")
+	}
+}
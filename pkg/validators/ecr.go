@@ -0,0 +1,145 @@
+package validators
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/openfaas/ofc-bootstrap/pkg/types"
+)
+
+var ecrHostPattern = regexp.MustCompile(`^([0-9]{12})\.dkr\.ecr\.([a-z0-9-]+)\.amazonaws\.com(\.cn)?$`)
+
+// ECRRegistry is the account and region of an ECR registry host
+type ECRRegistry struct {
+	AccountID string
+	Region    string
+}
+
+// ParseECRHost reads the account and region from a normalised registry
+// host such as 123456789012.dkr.ecr.eu-west-1.amazonaws.com
+func ParseECRHost(host string) (ECRRegistry, bool) {
+	match := ecrHostPattern.FindStringSubmatch(host)
+	if match == nil {
+		return ECRRegistry{}, false
+	}
+	return ECRRegistry{AccountID: match[1], Region: match[2]}, true
+}
+
+// ValidateECRConfig checks that the registry is on ECR in the region
+// given by ecr_config, and that every ECR credHelpers entry in the
+// registry auth file is for the same account and region
+func ValidateECRConfig(registry types.RegistryPrefix, region string, configFileBytes []byte) error {
+	ecr, ok := ParseECRHost(registry.Domain())
+	if !ok {
+		return fmt.Errorf("registry %q is not an ECR registry, with enable_ecr set registry: <account-id>.dkr.ecr.<region>.amazonaws.com/", registry.String())
+	}
+
+	if len(region) > 0 && ecr.Region != region {
+		return fmt.Errorf("registry %q is in region %s, not the ECR region %s", registry.String(), ecr.Region, region)
+	}
+
+	registryData, err := unmarshalRegistryConfig(configFileBytes)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range sortedKeys(registryData.CredHelpers) {
+		helper, ok := ParseECRHost(ServerHost(key))
+		if !ok {
+			continue
+		}
+		if helper != ecr {
+			return fmt.Errorf("credHelpers entry %s is for account %s in %s, but the registry is for account %s in %s. Run ofc-bootstrap registry-login --ecr again",
+				key, helper.AccountID, helper.Region, ecr.AccountID, ecr.Region)
+		}
+	}
+	return nil
+}
+
+// ExtractAWSProfile returns an AWS credentials file with only the given
+// profile, written as the default profile
+func ExtractAWSProfile(data []byte, profile string) ([]byte, error) {
+	if err := ValidateAWSCredentials(data, profile); err != nil {
+		return nil, err
+	}
+
+	profiles, err := parseINI(data)
+	if err != nil {
+		return nil, err
+	}
+	values := profiles[profile]
+
+	buf := bytes.Buffer{}
+	buf.WriteString("[default]\n")
+	for _, key := range sortedKeys(values) {
+		fmt.Fprintf(&buf, "%s = %s\n", key, values[key])
+	}
+	return buf.Bytes(), nil
+}
+
+// ECRPolicy is the minimal IAM policy for the builder to push images
+// and for ecr-create-repo to create repositories under the prefix of
+// the registry value
+func ECRPolicy(registry types.RegistryPrefix) (string, error) {
+	ecr, ok := ParseECRHost(registry.Domain())
+	if !ok {
+		return "", fmt.Errorf("registry %q is not an ECR registry", registry.String())
+	}
+
+	namespace := registry.Namespace
+	repositories := fmt.Sprintf("arn:aws:ecr:%s:%s:repository/*", ecr.Region, ecr.AccountID)
+	if len(namespace) > 0 {
+		repositories = fmt.Sprintf("arn:aws:ecr:%s:%s:repository/%s/*", ecr.Region, ecr.AccountID, strings.Trim(namespace, "/"))
+	}
+
+	type statement struct {
+		Sid      string   `json:"Sid"`
+		Effect   string   `json:"Effect"`
+		Action   []string `json:"Action"`
+		Resource string   `json:"Resource"`
+	}
+
+	policy := struct {
+		Version   string      `json:"Version"`
+		Statement []statement `json:"Statement"`
+	}{
+		Version: "2012-10-17",
+		Statement: []statement{
+			{
+				Sid:      "GetAuthorizationToken",
+				Effect:   "Allow",
+				Action:   []string{"ecr:GetAuthorizationToken"},
+				Resource: "*",
+			},
+			{
+				Sid:    "BuilderPushPull",
+				Effect: "Allow",
+				Action: []string{
+					"ecr:BatchCheckLayerAvailability",
+					"ecr:BatchGetImage",
+					"ecr:CompleteLayerUpload",
+					"ecr:GetDownloadUrlForLayer",
+					"ecr:InitiateLayerUpload",
+					"ecr:PutImage",
+					"ecr:UploadLayerPart",
+				},
+				Resource: repositories,
+			},
+			{
+				Sid:    "CreateRepository",
+				Effect: "Allow",
+				Action: []string{
+					"ecr:CreateRepository",
+					"ecr:DescribeRepositories",
+				},
+				Resource: repositories,
+			},
+		},
+	}
+
+	out, err := json.MarshalIndent(policy, "", "  ")
+	return string(out), err
+}
@@ -0,0 +1,104 @@
+package validators
+
+import (
+	"strings"
+	"testing"
+)
+
+const testAWSCredentials = `[default]
+aws_access_key_id = ADMINKEY
+aws_secret_access_key = ADMINSECRET
+
+[ofc-ecr]
+aws_access_key_id = ECRKEY
+aws_secret_access_key = ECRSECRET
+`
+
+func Test_ExtractAWSProfile(t *testing.T) {
+	got, err := ExtractAWSProfile([]byte(testAWSCredentials), "ofc-ecr")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `[default]
+aws_access_key_id = ECRKEY
+aws_secret_access_key = ECRSECRET
+`
+	if string(got) != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, string(got))
+	}
+	if strings.Contains(string(got), "ADMIN") {
+		t.Errorf("want no other profiles in the extracted file")
+	}
+}
+
+func Test_ExtractAWSProfile_Missing(t *testing.T) {
+	_, err := ExtractAWSProfile([]byte(testAWSCredentials), "prod")
+	want := "profile [prod] not found in AWS credentials file"
+	if err == nil || err.Error() != want {
+		t.Errorf("want error: %s, got: %v", want, err)
+	}
+}
+
+func Test_ValidateECRConfig(t *testing.T) {
+	registry := "123456789012.dkr.ecr.eu-west-1.amazonaws.com/ofc/"
+	matching := []byte(`{"credsStore": "ecr-login", "credHelpers": {"123456789012.dkr.ecr.eu-west-1.amazonaws.com": "ecr-login"}}`)
+
+	cases := []struct {
+		name     string
+		registry string
+		region   string
+		file     []byte
+		wantErr  string
+	}{
+		{name: "consistent", registry: registry, region: "eu-west-1", file: matching},
+		{
+			name: "not ECR", registry: "docker.io/ofctest/", region: "eu-west-1", file: matching,
+			wantErr: `registry "docker.io/ofctest/" is not an ECR registry, with enable_ecr set registry: <account-id>.dkr.ecr.<region>.amazonaws.com/`,
+		},
+		{
+			name: "region", registry: registry, region: "us-east-1", file: matching,
+			wantErr: `registry "123456789012.dkr.ecr.eu-west-1.amazonaws.com/ofc/" is in region eu-west-1, not the ECR region us-east-1`,
+		},
+		{
+			name: "credHelpers account", registry: registry, region: "eu-west-1",
+			file:    []byte(`{"credsStore": "ecr-login", "credHelpers": {"210987654321.dkr.ecr.eu-west-1.amazonaws.com": "ecr-login"}}`),
+			wantErr: "credHelpers entry 210987654321.dkr.ecr.eu-west-1.amazonaws.com is for account 210987654321 in eu-west-1, but the registry is for account 123456789012 in eu-west-1. Run ofc-bootstrap registry-login --ecr again",
+		},
+	}
+
+	for _, c := range cases {
+		err := ValidateECRConfig(parseRegistry(t, c.registry), c.region, c.file)
+		if len(c.wantErr) == 0 {
+			if err != nil {
+				t.Errorf("%s want no error, got: %s", c.name, err.Error())
+			}
+			continue
+		}
+		if err == nil || err.Error() != c.wantErr {
+			t.Errorf("%s want error: %s, got: %v", c.name, c.wantErr, err)
+		}
+	}
+}
+
+func Test_ECRPolicy(t *testing.T) {
+	policy, err := ECRPolicy(parseRegistry(t, "123456789012.dkr.ecr.eu-west-1.amazonaws.com/ofc/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`"ecr:GetAuthorizationToken"`,
+		`"ecr:PutImage"`,
+		`"ecr:CreateRepository"`,
+		`"Resource": "arn:aws:ecr:eu-west-1:123456789012:repository/ofc/*"`,
+	} {
+		if !strings.Contains(policy, want) {
+			t.Errorf("want policy to contain %s, got:\n%s", want, policy)
+		}
+	}
+
+	if strings.Contains(policy, "ecr:*") {
+		t.Errorf("want no wildcard actions, got:\n%s", policy)
+	}
+}
@@ -0,0 +1,231 @@
+package validators
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+const (
+	// PEMRSAFormat is an RSA private key in PEM format
+	PEMRSAFormat = "pem-rsa"
+	// JSONFormat is any valid JSON document
+	JSONFormat = "json"
+	// GCPServiceAccountFormat is a key exported for a GCP service account
+	GCPServiceAccountFormat = "gcp-service-account"
+	// AWSCredentialsFormat is an AWS shared credentials file with a default profile
+	AWSCredentialsFormat = "aws-credentials"
+	// SingleLineTokenFormat is a token without whitespace or new-lines
+	SingleLineTokenFormat = "single-line-token"
+	// DockerConfigJSONFormat is a Docker config.json file
+	DockerConfigJSONFormat = "dockerconfigjson"
+)
+
+// Formats lists the supported formats for secret files
+var Formats = []string{
+	PEMRSAFormat,
+	JSONFormat,
+	GCPServiceAccountFormat,
+	AWSCredentialsFormat,
+	SingleLineTokenFormat,
+	DockerConfigJSONFormat,
+}
+
+// ValidateFormat checks that data matches the given format and
+// returns an error which describes what is wrong otherwise
+func ValidateFormat(format string, data []byte) error {
+	switch format {
+	case PEMRSAFormat:
+		return validatePEMRSA(data)
+	case JSONFormat:
+		_, err := unmarshalJSON(data)
+		return err
+	case GCPServiceAccountFormat:
+		return validateGCPServiceAccount(data)
+	case AWSCredentialsFormat:
+		return ValidateAWSCredentials(data, "default")
+	case SingleLineTokenFormat:
+		return validateSingleLineToken(data)
+	case DockerConfigJSONFormat:
+		return validateDockerConfigJSON(data)
+	}
+
+	return fmt.Errorf("unsupported format: %q, use one of: %s", format, strings.Join(Formats, ", "))
+}
+
+// IsFormat is true when format is supported by ValidateFormat
+func IsFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+func validatePEMRSA(data []byte) error {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return fmt.Errorf("no PEM data found, expected an RSA private key")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		if _, err := x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return fmt.Errorf("unable to parse RSA private key: %s", err.Error())
+		}
+		return nil
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("unable to parse private key: %s", err.Error())
+		}
+		if _, ok := key.(*rsa.PrivateKey); !ok {
+			return fmt.Errorf("private key is of type %T, expected an RSA private key", key)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("found PEM block %q, expected an RSA private key", block.Type)
+}
+
+func unmarshalJSON(data []byte) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			line := bytes.Count(data[:syntaxErr.Offset], []byte("\n")) + 1
+			return nil, fmt.Errorf("invalid JSON on line %d: %s", line, syntaxErr.Error())
+		}
+		return nil, fmt.Errorf("invalid JSON: %s", err.Error())
+	}
+	return doc, nil
+}
+
+func validateGCPServiceAccount(data []byte) error {
+	doc, err := unmarshalJSON(data)
+	if err != nil {
+		return err
+	}
+
+	if keyType, _ := doc["type"].(string); keyType != "service_account" {
+		return fmt.Errorf("field \"type\" is %q, expected \"service_account\"", keyType)
+	}
+
+	for _, field := range []string{"project_id", "private_key", "client_email"} {
+		if value, _ := doc[field].(string); len(value) == 0 {
+			return fmt.Errorf("field %q is missing from the service account key", field)
+		}
+	}
+	return nil
+}
+
+// ValidateAWSCredentials checks that an AWS shared credentials
+// file has an access key pair for the given profile
+func ValidateAWSCredentials(data []byte, profile string) error {
+	profiles, err := parseINI(data)
+	if err != nil {
+		return err
+	}
+
+	values, ok := profiles[profile]
+	if !ok {
+		return fmt.Errorf("profile [%s] not found in AWS credentials file", profile)
+	}
+
+	for _, key := range []string{"aws_access_key_id", "aws_secret_access_key"} {
+		if len(values[key]) == 0 {
+			return fmt.Errorf("profile [%s] has no value for %s", profile, key)
+		}
+	}
+	return nil
+}
+
+// parseINI reads the sections and key/value pairs of an INI file
+func parseINI(data []byte) (map[string]map[string]string, error) {
+	sections := map[string]map[string]string{}
+	section := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") || strings.HasPrefix(text, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+			section = strings.TrimSpace(text[1 : len(text)-1])
+			sections[section] = map[string]string{}
+			continue
+		}
+
+		parts := strings.SplitN(text, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected key = value, got: %q", line, text)
+		}
+		if len(section) == 0 {
+			return nil, fmt.Errorf("line %d: key %q is outside of a [profile] section", line, strings.TrimSpace(parts[0]))
+		}
+		sections[section][strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return sections, scanner.Err()
+}
+
+func validateSingleLineToken(data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("token is empty")
+	}
+
+	if i := bytes.IndexAny(data, "\r\n"); i > -1 {
+		if i == len(bytes.TrimRight(data, "\r\n")) {
+			return fmt.Errorf("token has a trailing new-line, remove it i.e. with: printf %%s \"$(cat file)\" > file")
+		}
+		return fmt.Errorf("token spans more than one line")
+	}
+
+	if len(bytes.TrimSpace(data)) != len(data) {
+		return fmt.Errorf("token has leading or trailing whitespace")
+	}
+	return nil
+}
+
+func validateDockerConfigJSON(data []byte) error {
+	if _, err := unmarshalJSON(data); err != nil {
+		return err
+	}
+
+	var config struct {
+		DockerConfigJson
+		CredsStore  string            `json:"credsStore"`
+		CredHelpers map[string]string `json:"credHelpers"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("unexpected structure: %s", err.Error())
+	}
+
+	if len(config.AuthConfigs) == 0 && len(config.CredHelpers) == 0 && len(config.CredsStore) == 0 {
+		return fmt.Errorf("no \"auths\", \"credHelpers\" or \"credsStore\" entries found")
+	}
+
+	for server, auth := range config.AuthConfigs {
+		if len(auth.Auth) == 0 {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return fmt.Errorf("auth for %q is not valid base64: %s", server, err.Error())
+		}
+		if !strings.Contains(string(decoded), ":") {
+			return fmt.Errorf("auth for %q should decode to username:password", server)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package validators
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+)
+
+func Test_ValidateFormat(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecDER, _ := x509.MarshalECPrivateKey(ecKey)
+	ecPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER})
+
+	tests := []struct {
+		title   string
+		format  string
+		data    string
+		wantErr string
+	}{
+		{
+			title:  "RSA private key",
+			format: PEMRSAFormat,
+			data:   string(rsaPEM),
+		},
+		{
+			title:   "EC private key given for pem-rsa",
+			format:  PEMRSAFormat,
+			data:    string(ecPEM),
+			wantErr: `found PEM block "EC PRIVATE KEY"`,
+		},
+		{
+			title:   "not PEM",
+			format:  PEMRSAFormat,
+			data:    "hello",
+			wantErr: "no PEM data found",
+		},
+		{
+			title:   "JSON with a syntax error on line 2",
+			format:  JSONFormat,
+			data:    "{\n  \"a\": ,\n}",
+			wantErr: "invalid JSON on line 2",
+		},
+		{
+			title:  "GCP service account",
+			format: GCPServiceAccountFormat,
+			data:   `{"type": "service_account", "project_id": "p", "private_key": "k", "client_email": "e@p.iam.gserviceaccount.com"}`,
+		},
+		{
+			title:   "GCP service account without client_email",
+			format:  GCPServiceAccountFormat,
+			data:    `{"type": "service_account", "project_id": "p", "private_key": "k"}`,
+			wantErr: `field "client_email" is missing`,
+		},
+		{
+			title:   "GCP OAuth client instead of service account",
+			format:  GCPServiceAccountFormat,
+			data:    `{"installed": {}}`,
+			wantErr: `expected "service_account"`,
+		},
+		{
+			title:  "AWS credentials",
+			format: AWSCredentialsFormat,
+			data:   "[default]\naws_access_key_id = AKIA\naws_secret_access_key = secret\n",
+		},
+		{
+			title:   "AWS credentials without a default profile",
+			format:  AWSCredentialsFormat,
+			data:    "[admin]\naws_access_key_id = AKIA\naws_secret_access_key = secret\n",
+			wantErr: "profile [default] not found",
+		},
+		{
+			title:   "AWS credentials without a secret key",
+			format:  AWSCredentialsFormat,
+			data:    "[default]\naws_access_key_id = AKIA\n",
+			wantErr: "no value for aws_secret_access_key",
+		},
+		{
+			title:  "single line token",
+			format: SingleLineTokenFormat,
+			data:   "abc123",
+		},
+		{
+			title:   "token with trailing new-line",
+			format:  SingleLineTokenFormat,
+			data:    "abc123\n",
+			wantErr: "trailing new-line",
+		},
+		{
+			title:   "token over two lines",
+			format:  SingleLineTokenFormat,
+			data:    "abc\n123",
+			wantErr: "more than one line",
+		},
+		{
+			title:   "empty token",
+			format:  SingleLineTokenFormat,
+			data:    "",
+			wantErr: "token is empty",
+		},
+		{
+			title:  "docker config with auths",
+			format: DockerConfigJSONFormat,
+			data:   `{"auths": {"https://index.docker.io/v1/": {"auth": "Zm9vOmJhcg=="}}}`,
+		},
+		{
+			title:  "docker config with credHelpers",
+			format: DockerConfigJSONFormat,
+			data:   `{"credsStore": "ecr-login", "credHelpers": {"1.dkr.ecr.eu-central-1.amazonaws.com": "ecr-login"}}`,
+		},
+		{
+			title:   "docker config without entries",
+			format:  DockerConfigJSONFormat,
+			data:    `{}`,
+			wantErr: "no \"auths\"",
+		},
+		{
+			title:   "docker config with auth missing a password",
+			format:  DockerConfigJSONFormat,
+			data:    `{"auths": {"https://index.docker.io/v1/": {"auth": "Zm9v"}}}`,
+			wantErr: "username:password",
+		},
+		{
+			title:   "unknown format",
+			format:  "yaml",
+			data:    "a: b",
+			wantErr: "unsupported format",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			err := ValidateFormat(test.format, []byte(test.data))
+			if len(test.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("want no error, got: %s", err.Error())
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("want error containing: %q, got: %v", test.wantErr, err)
+			}
+		})
+	}
+}
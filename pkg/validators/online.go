@@ -0,0 +1,279 @@
+package validators
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/openfaas/ofc-bootstrap/pkg/types"
+)
+
+// CheckRepository is the repository, under the namespace of the registry
+// value, on which push access is checked. Nothing is written to it, the
+// upload which is started is cancelled straight away.
+const CheckRepository = "ofc-bootstrap-check"
+
+// RegistryChecker performs the Docker Registry v2 auth handshake to
+// check that credentials are valid and have push access
+type RegistryChecker struct {
+	Client *http.Client
+}
+
+// NewRegistryChecker returns a RegistryChecker with a timeout suitable
+// for running before apply
+func NewRegistryChecker() *RegistryChecker {
+	return &RegistryChecker{
+		Client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// RegistryEndpoint returns the base URL of the v2 API and the repository
+// prefix for a registry plan value such as docker.io/ofctest/ or
+// registry.example.com:5000/team/. Docker Hub is served from
+// registry-1.docker.io, and localhost is accessed over plain HTTP.
+func RegistryEndpoint(registry types.RegistryPrefix) (string, string) {
+	host := registry.Domain()
+
+	scheme := "https"
+	if host == "localhost" || strings.HasPrefix(host, "localhost:") || strings.HasPrefix(host, "127.0.0.1") {
+		scheme = "http"
+	}
+	if host == DockerHubHost {
+		host = "registry-1.docker.io"
+	}
+
+	return scheme + "://" + host, registry.Namespace
+}
+
+// CheckPush logs into the registry at baseURL with the given credentials
+// and starts a blob upload on namespace/ofc-bootstrap-check, which is
+// only accepted with push access
+func (c *RegistryChecker) CheckPush(baseURL, namespace, username, password string) error {
+	repository := CheckRepository
+	if len(namespace) > 0 {
+		repository = strings.Trim(namespace, "/") + "/" + CheckRepository
+	}
+
+	res, err := c.Client.Get(baseURL + "/v2/")
+	if err != nil {
+		return fmt.Errorf("unable to reach registry %s: %s", baseURL, err.Error())
+	}
+	res.Body.Close()
+
+	authorization := ""
+	switch res.StatusCode {
+	case http.StatusOK:
+		// The registry allows anonymous access, credentials are sent if
+		// the upload is challenged
+	case http.StatusUnauthorized:
+		authorization, err = c.authorize(baseURL, res.Header.Get("WWW-Authenticate"), repository, username, password)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("registry %s is not a v2 registry, %s/v2/ gave status code: %d", baseURL, baseURL, res.StatusCode)
+	}
+
+	res, err = c.startUpload(baseURL, repository, authorization)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode == http.StatusUnauthorized && len(authorization) == 0 {
+		authorization, err = c.authorize(baseURL, res.Header.Get("WWW-Authenticate"), repository, username, password)
+		if err != nil {
+			return err
+		}
+		if res, err = c.startUpload(baseURL, repository, authorization); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case res.StatusCode == http.StatusAccepted:
+		c.cancelUpload(baseURL, res.Header.Get("Location"), authorization)
+		return nil
+	case res.StatusCode == http.StatusUnauthorized && strings.HasPrefix(authorization, "Basic "):
+		// Basic credentials are only checked when they are used, unlike
+		// a bearer token which is only issued for valid credentials
+		return fmt.Errorf("registry rejected the credentials for %s, log in again", username)
+	case res.StatusCode == http.StatusUnauthorized, res.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("credentials for %s are valid, but do not have push access to %s", username, repository)
+	}
+	return fmt.Errorf("starting an upload to %s gave status code: %d", repository, res.StatusCode)
+}
+
+// authorize answers the WWW-Authenticate challenge of the registry with
+// basic auth or a bearer token, and returns the Authorization header
+func (c *RegistryChecker) authorize(baseURL, challenge, repository, username, password string) (string, error) {
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		return "Basic " + basicAuth(username, password), nil
+	case "bearer":
+		token, err := c.token(params, repository, username, password)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	}
+	return "", fmt.Errorf("registry %s gave an unsupported auth challenge: %q", baseURL, challenge)
+}
+
+// startUpload starts a blob upload on the repository, the body of the
+// response is closed
+func (c *RegistryChecker) startUpload(baseURL, repository, authorization string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/v2/"+repository+"/blobs/uploads/", nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(authorization) > 0 {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	res, err := c.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach registry %s: %s", baseURL, err.Error())
+	}
+	res.Body.Close()
+	return res, nil
+}
+
+// token requests a bearer token for push and pull on the repository
+func (c *RegistryChecker) token(params map[string]string, repository, username, password string) (string, error) {
+	realm := params["realm"]
+	if len(realm) == 0 {
+		return "", fmt.Errorf("registry gave a bearer challenge with no realm")
+	}
+
+	u, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("invalid token realm %q: %s", realm, err.Error())
+	}
+	query := u.Query()
+	if service, ok := params["service"]; ok {
+		query.Set("service", service)
+	}
+	query.Set("scope", "repository:"+repository+":push,pull")
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(username, password)
+
+	res, err := c.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to reach token endpoint %s: %s", realm, err.Error())
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+		return "", fmt.Errorf("registry rejected the credentials for %s, log in again", username)
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint %s gave status code: %d", realm, res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+
+	tokenRes := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.Unmarshal(body, &tokenRes); err != nil {
+		return "", fmt.Errorf("unable to parse token response: %s", err.Error())
+	}
+
+	if len(tokenRes.Token) > 0 {
+		return tokenRes.Token, nil
+	}
+	if len(tokenRes.AccessToken) > 0 {
+		return tokenRes.AccessToken, nil
+	}
+	return "", fmt.Errorf("token endpoint %s gave no token", realm)
+}
+
+// cancelUpload deletes the upload started by CheckPush, errors are
+// ignored since the registry expires abandoned uploads
+func (c *RegistryChecker) cancelUpload(baseURL, location, authorization string) {
+	if len(location) == 0 {
+		return
+	}
+	if strings.HasPrefix(location, "/") {
+		location = baseURL + location
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, location, nil)
+	if err != nil {
+		return
+	}
+	if len(authorization) > 0 {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	if res, err := c.Client.Do(req); err == nil {
+		res.Body.Close()
+	}
+}
+
+// parseChallenge parses a WWW-Authenticate header such as:
+// Bearer realm="https://auth.docker.io/token",service="registry.docker.io"
+func parseChallenge(header string) (string, map[string]string) {
+	params := map[string]string{}
+
+	header = strings.TrimSpace(header)
+	i := strings.Index(header, " ")
+	if i == -1 {
+		return header, params
+	}
+	scheme := header[:i]
+	rest := header[i+1:]
+
+	for len(rest) > 0 {
+		rest = strings.TrimLeft(rest, " ,")
+		eq := strings.Index(rest, "=")
+		if eq == -1 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end == -1 {
+				value = rest[1:]
+				rest = ""
+			} else {
+				value = rest[1 : end+1]
+				rest = rest[end+2:]
+			}
+		} else {
+			end := strings.Index(rest, ",")
+			if end == -1 {
+				value = rest
+				rest = ""
+			} else {
+				value = rest[:end]
+				rest = rest[end:]
+			}
+		}
+		params[key] = value
+	}
+
+	return scheme, params
+}
+
+func basicAuth(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
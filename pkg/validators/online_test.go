@@ -0,0 +1,234 @@
+package validators
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeRegistry behaves like a v2 registry with token auth, where
+// "builder" can push to the ofctest namespace and "reader" can only pull.
+// With anonymousPull, /v2/ is served without a challenge.
+func fakeRegistry(t *testing.T, uploads *int, anonymousPull bool) *httptest.Server {
+	var server *httptest.Server
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if got := r.URL.Query().Get("service"); got != "test-registry" {
+			t.Errorf("service want: test-registry, got: %s", got)
+		}
+
+		scope := r.URL.Query().Get("scope")
+		token := "pull-only"
+		if username == "builder" && scope == "repository:ofctest/"+CheckRepository+":push,pull" {
+			token = "push"
+		}
+		json.NewEncoder(w).Encode(map[string]string{"token": token})
+	})
+
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		challenge := fmt.Sprintf(`Bearer realm="%s/token",service="test-registry"`, server.URL)
+
+		if r.URL.Path == "/v2/" && anonymousPull {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.URL.Path == "/v2/" {
+			w.Header().Set("WWW-Authenticate", challenge)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer push" {
+			w.Header().Set("WWW-Authenticate", challenge)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/blobs/uploads/"):
+			*uploads++
+			w.Header().Set("Location", r.URL.Path+"upload-1")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodDelete && strings.HasSuffix(r.URL.Path, "/upload-1"):
+			*uploads--
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	server = httptest.NewServer(mux)
+	return server
+}
+
+func Test_CheckPush_BearerToken(t *testing.T) {
+	for _, anonymousPull := range []bool{false, true} {
+		t.Run(fmt.Sprintf("anonymous pull %t", anonymousPull), func(t *testing.T) {
+			testCheckPushBearerToken(t, anonymousPull)
+		})
+	}
+}
+
+// testCheckPushBearerToken checks that credentials are sent, even when
+// /v2/ needs none and the challenge only comes from the upload
+func testCheckPushBearerToken(t *testing.T, anonymousPull bool) {
+	uploads := 0
+	server := fakeRegistry(t, &uploads, anonymousPull)
+	defer server.Close()
+
+	checker := &RegistryChecker{Client: server.Client()}
+
+	cases := []struct {
+		name     string
+		username string
+		password string
+		wantErr  string
+	}{
+		{name: "push access", username: "builder", password: "secret"},
+		{name: "wrong password", username: "builder", password: "expired", wantErr: "registry rejected the credentials for builder, log in again"},
+		{name: "pull only", username: "reader", password: "secret", wantErr: "credentials for reader are valid, but do not have push access to ofctest/" + CheckRepository},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := checker.CheckPush(server.URL, "ofctest/", c.username, c.password)
+			if len(c.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("want no error, got: %s", err.Error())
+				}
+				return
+			}
+			if err == nil || err.Error() != c.wantErr {
+				t.Errorf("want error: %s, got: %v", c.wantErr, err)
+			}
+		})
+	}
+
+	if uploads != 0 {
+		t.Errorf("want uploads to be cancelled, %d left open", uploads)
+	}
+}
+
+// Test_CheckPush_BasicAuth uses a registry like registry:2 with htpasswd,
+// where "admin" can push and "viewer" can only pull
+func Test_CheckPush_BasicAuth(t *testing.T) {
+	users := map[string]string{"admin": "secret", "viewer": "secret"}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || users[username] != password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Registry Realm"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodPost {
+			if username != "admin" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	checker := &RegistryChecker{Client: server.Client()}
+
+	cases := []struct {
+		name     string
+		username string
+		password string
+		wantErr  string
+	}{
+		{name: "push access", username: "admin", password: "secret"},
+		{name: "wrong password", username: "admin", password: "wrong", wantErr: "registry rejected the credentials for admin, log in again"},
+		{name: "pull only", username: "viewer", password: "secret", wantErr: "credentials for viewer are valid, but do not have push access to " + CheckRepository},
+	}
+
+	for _, c := range cases {
+		err := checker.CheckPush(server.URL, "", c.username, c.password)
+		if len(c.wantErr) == 0 {
+			if err != nil {
+				t.Errorf("%s want no error, got: %s", c.name, err.Error())
+			}
+			continue
+		}
+		if err == nil || err.Error() != c.wantErr {
+			t.Errorf("%s want error: %s, got: %v", c.name, c.wantErr, err)
+		}
+	}
+}
+
+func Test_CheckPush_NotARegistry(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	checker := &RegistryChecker{Client: server.Client()}
+
+	err := checker.CheckPush(server.URL, "", "admin", "secret")
+	want := fmt.Sprintf("registry %s is not a v2 registry, %s/v2/ gave status code: 404", server.URL, server.URL)
+	if err == nil || err.Error() != want {
+		t.Errorf("want error: %s, got: %v", want, err)
+	}
+}
+
+func Test_RegistryEndpoint(t *testing.T) {
+	cases := []struct {
+		registry      string
+		wantURL       string
+		wantNamespace string
+	}{
+		{registry: "docker.io/ofctest/", wantURL: "https://registry-1.docker.io", wantNamespace: "ofctest"},
+		{registry: "ofctest/", wantURL: "https://registry-1.docker.io", wantNamespace: "ofctest"},
+		{registry: "ghcr.io/openfaas/team/", wantURL: "https://ghcr.io", wantNamespace: "openfaas/team"},
+		{registry: "registry.example.com:5000/", wantURL: "https://registry.example.com:5000", wantNamespace: ""},
+		{registry: "localhost:5000/ofc/", wantURL: "http://localhost:5000", wantNamespace: "ofc"},
+	}
+
+	for _, c := range cases {
+		gotURL, gotNamespace := RegistryEndpoint(parseRegistry(t, c.registry))
+		if gotURL != c.wantURL || gotNamespace != c.wantNamespace {
+			t.Errorf("%s want: %s %q, got: %s %q", c.registry, c.wantURL, c.wantNamespace, gotURL, gotNamespace)
+		}
+	}
+}
+
+func Test_parseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:a/b:pull"`)
+
+	if scheme != "Bearer" {
+		t.Errorf("scheme want: Bearer, got: %s", scheme)
+	}
+	want := map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:a/b:pull",
+	}
+	for k, v := range want {
+		if params[k] != v {
+			t.Errorf("%s want: %s, got: %s", k, v, params[k])
+		}
+	}
+}
+
+func Test_RegistryCredentials(t *testing.T) {
+	file := []byte(`{"auths": {"https://index.docker.io/v1/": {"auth": "YWRtaW46czNjcmV0OjE="}}}`)
+
+	username, password, err := RegistryCredentials(parseRegistry(t, "docker.io/ofctest/"), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "admin" || password != "s3cret:1" {
+		t.Errorf("want admin s3cret:1, got: %s %s", username, password)
+	}
+}
@@ -5,29 +5,74 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
+
+	"github.com/openfaas/ofc-bootstrap/pkg/types"
 )
 
+// DockerHubHost is the normalised host for Docker Hub, which is also
+// known as index.docker.io, registry-1.docker.io and registry.hub.docker.com
+const DockerHubHost = types.DockerHubDomain
+
+// DockerHubServer is the server which the Docker CLI uses as the auths
+// key and credential helper key for Docker Hub
+const DockerHubServer = "https://index.docker.io/v1/"
+
+// ClusterHelpers are the credential helpers available to the builder
+// in the cluster, without the docker-credential- prefix
+var ClusterHelpers = []string{"ecr-login"}
+
 type AuthConfig struct {
 	Auth string `json:"auth,omitempty"`
 }
 
 type DockerConfigJson struct {
 	AuthConfigs map[string]AuthConfig `json:"auths"`
+	CredHelpers map[string]string     `json:"credHelpers,omitempty"`
+	CredsStore  string                `json:"credsStore,omitempty"`
 }
 
-func ValidateRegistryAuth(registryEndpoint string, configFileBytes []byte) error {
+// RegistryAuth is where the credentials for a registry are found in
+// a Docker config.json file
+type RegistryAuth struct {
+	// Host is the normalised registry host
+	Host string
 
-	registryData, err := unmarshalRegistryConfig(configFileBytes)
-	if err != nil {
-		return err
+	// Key of the auths entry which holds the credentials
+	Key  string
+	Auth string
+
+	// Helper is the credential helper which holds the credentials,
+	// without the docker-credential- prefix
+	Helper string
+}
+
+// Source describes the auths entry or helper which holds the credentials
+func (r RegistryAuth) Source() string {
+	if len(r.Helper) > 0 {
+		return "credential helper docker-credential-" + r.Helper
 	}
+	return fmt.Sprintf("auths entry %q", r.Key)
+}
 
-	if err := validate(registryData, registryEndpoint); err != nil {
-		return err
+func ValidateRegistryAuth(registry types.RegistryPrefix, configFileBytes []byte) error {
+	_, err := FindRegistryAuth(registry, configFileBytes)
+	return err
+}
+
+// FindRegistryAuth finds the credentials for a registry in a Docker
+// config.json file, in the same order as the Docker CLI: a credHelpers
+// entry for the host, then the credsStore, then an auths entry with
+// credentials. Inline auths are never used while a credsStore is set.
+// Helpers must be available to the builder in the cluster.
+func FindRegistryAuth(registry types.RegistryPrefix, configFileBytes []byte) (*RegistryAuth, error) {
+	registryData, err := unmarshalRegistryConfig(configFileBytes)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return findRegistryAuth(registryData, registry.Domain())
 }
 
 func unmarshalRegistryConfig(data []byte) (*DockerConfigJson, error) {
@@ -40,20 +85,122 @@ func unmarshalRegistryConfig(data []byte) (*DockerConfigJson, error) {
 	return &registryConfig, nil
 }
 
-func validate(registryData *DockerConfigJson, endpoint string) error {
-	var fileEndpoint string
-	if strings.HasPrefix(endpoint, "docker.io") {
-		fileEndpoint = "https://index.docker.io/v1/"
-	} else {
-		fileEndpoint = endpoint
+// RegistryCredentials returns the username and password stored for the
+// registry in a Docker config.json file
+func RegistryCredentials(registry types.RegistryPrefix, configFileBytes []byte) (string, string, error) {
+	registryAuth, err := FindRegistryAuth(registry, configFileBytes)
+	if err != nil {
+		return "", "", err
+	}
+
+	if len(registryAuth.Helper) > 0 {
+		return "", "", fmt.Errorf("credentials for %s are held by docker-credential-%s and cannot be read", registryAuth.Host, registryAuth.Helper)
+	}
+
+	decoded, _ := base64.StdEncoding.DecodeString(registryAuth.Auth)
+	parts := strings.SplitN(strings.TrimSpace(string(decoded)), ":", 2)
+	if len(parts) != 2 {
+		return "", "", errors.New("docker credentials file is not valid (auth must be username:password). Please re-create this file")
+	}
+	return parts[0], parts[1], nil
+}
+
+// ServerHost returns the host of a server given to docker login, such as
+// the keys of a Docker config.json file i.e. https://index.docker.io/v1/
+// or ghcr.io. Any scheme and path are removed, and the host is normalised
+// in the same way as the domain of a types.RegistryPrefix.
+func ServerHost(server string) string {
+	value := strings.TrimSpace(server)
+	if i := strings.Index(value, "://"); i > -1 {
+		value = value[i+len("://"):]
+	}
+
+	return types.NormaliseRegistryHost(strings.SplitN(value, "/", 2)[0])
+}
+
+func findRegistryAuth(registryData *DockerConfigJson, host string) (*RegistryAuth, error) {
+	registryAuth, err := lookupRegistryAuth(registryData, host)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(registryAuth.Helper) > 0 {
+		if err := checkClusterHelper(registryAuth); err != nil {
+			return nil, err
+		}
+	}
+	return registryAuth, nil
+}
+
+// lookupRegistryAuth finds the auths entry or helper for the normalised
+// registry host, whether or not the helper is available in the cluster
+func lookupRegistryAuth(registryData *DockerConfigJson, host string) (*RegistryAuth, error) {
+	registryAuth := &RegistryAuth{Host: host}
+	for _, key := range sortedKeys(registryData.CredHelpers) {
+		if ServerHost(key) == host && len(registryData.CredHelpers[key]) > 0 {
+			registryAuth.Helper = registryData.CredHelpers[key]
+			return registryAuth, nil
+		}
+	}
+
+	if len(registryData.CredsStore) > 0 {
+		registryAuth.Helper = registryData.CredsStore
+		return registryAuth, nil
 	}
-	if endpointConfig, ok := registryData.AuthConfigs[fileEndpoint]; ok {
+
+	found := false
+	for _, key := range sortedKeys(registryData.AuthConfigs) {
+		if ServerHost(key) != host {
+			continue
+		}
+		found = true
+		endpointConfig := registryData.AuthConfigs[key]
+
 		if endpointConfig.Auth != "" {
-			_, err := base64.StdEncoding.DecodeString(endpointConfig.Auth)
-			return err
-		} else {
-			return errors.New("docker credentials file is not valid (no base64 credentials). Please re-create this file")
+			if _, err := base64.StdEncoding.DecodeString(endpointConfig.Auth); err != nil {
+				return nil, err
+			}
+			registryAuth.Key = key
+			registryAuth.Auth = endpointConfig.Auth
+			return registryAuth, nil
+		}
+	}
+
+	if found {
+		return nil, errors.New("docker credentials file is not valid (no base64 credentials). Please re-create this file")
+	}
+	return nil, fmt.Errorf("docker auth file does not contain registry %q that you specified in config. Please use docker login", host)
+}
+
+func validate(registryData *DockerConfigJson, server string) error {
+	_, err := findRegistryAuth(registryData, ServerHost(server))
+	return err
+}
+
+// checkClusterHelper returns an error when the helper holding the
+// credentials cannot be used by the builder in the cluster
+func checkClusterHelper(registryAuth *RegistryAuth) error {
+	for _, available := range ClusterHelpers {
+		if registryAuth.Helper == available {
+			return nil
+		}
+	}
+	return fmt.Errorf("credentials for %s are held by docker-credential-%s, which is not available in the cluster. Use ofc-bootstrap registry-login to write them to the file instead",
+		registryAuth.Host, registryAuth.Helper)
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	switch v := m.(type) {
+	case map[string]string:
+		for key := range v {
+			keys = append(keys, key)
+		}
+	case map[string]AuthConfig:
+		for key := range v {
+			keys = append(keys, key)
 		}
 	}
-	return errors.New(fmt.Sprintf("docker auth file does not contain registry %q that you specified in config. Please use docker login", endpoint))
+	sort.Strings(keys)
+	return keys
 }
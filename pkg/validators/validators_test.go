@@ -2,15 +2,28 @@ package validators
 
 import (
 	"fmt"
+	"strings"
 	"testing"
+
+	"github.com/openfaas/ofc-bootstrap/pkg/types"
 )
 
+// parseRegistry parses a registry plan value for the validators
+func parseRegistry(t *testing.T, value string) types.RegistryPrefix {
+	t.Helper()
+	registry, err := types.ParseRegistry(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return registry
+}
+
 func Test_ValidateRegistryAuthNoCredStore(t *testing.T) {
 	file := []byte(fmt.Sprintf("{ \"auths\": { \"%s\": {\"auth\": \"%s\"} } } ",
 		"https://index.docker.io/v1/",
 		"Zm9vCg=="))
 
-	got := ValidateRegistryAuth("docker.io/some-user", file)
+	got := ValidateRegistryAuth(parseRegistry(t, "docker.io/some-user/"), file)
 	if got != nil {
 		t.Errorf("error want: %s, got %s", "nil", got)
 		t.Fail()
@@ -20,7 +33,7 @@ func Test_ValidateRegistryAuthNoCredStore(t *testing.T) {
 func Test_ValidateRegistryAuthCredStoreNoAuth(t *testing.T) {
 	file := []byte(fmt.Sprintf("{ \"auths\": { \"%s\": {} }, \"credsStore\": \"something\" } ",
 		"https://index.docker.io/v1/"))
-	got := ValidateRegistryAuth("docker.io/some-user", file)
+	got := ValidateRegistryAuth(parseRegistry(t, "docker.io/some-user/"), file)
 	if got == nil {
 		t.Errorf("error was nil.")
 		t.Fail()
@@ -30,7 +43,7 @@ func Test_ValidateRegistryAuthCredStoreNoAuth(t *testing.T) {
 func Test_ValidateRegistryAuthNoCredStoreNoAuth(t *testing.T) {
 	file := []byte(fmt.Sprintf("{ \"auths\": { \"%s\": {} } } ",
 		"index.docker.io/index.html"))
-	got := ValidateRegistryAuth("docker.io/some-user", file)
+	got := ValidateRegistryAuth(parseRegistry(t, "docker.io/some-user/"), file)
 	if got == nil {
 		t.Errorf("error was nil.")
 		t.Fail()
@@ -40,7 +53,7 @@ func Test_ValidateRegistryAuthNoCredStoreNoAuth(t *testing.T) {
 func Test_ValidateRegistryAuthNoValidEndpoint(t *testing.T) {
 	file := []byte(fmt.Sprintf("{ \"auths\": { \"%s\": {} } } ",
 		""))
-	got := ValidateRegistryAuth("docker.io/some-user", file)
+	got := ValidateRegistryAuth(parseRegistry(t, "docker.io/some-user/"), file)
 	if got == nil {
 		t.Errorf("error was nil.")
 		t.Fail()
@@ -129,3 +142,113 @@ func createDockerConfigJson(endpoint string, authString string) *DockerConfigJso
 
 	return &conf
 }
+
+func Test_ServerHost(t *testing.T) {
+	cases := map[string]string{
+		"https://index.docker.io/v1/":         "docker.io",
+		"registry-1.docker.io":                "docker.io",
+		"registry.hub.docker.com":             "docker.io",
+		"docker.io":                           "docker.io",
+		"ghcr.io":                             "ghcr.io",
+		"https://GHCR.io":                     "ghcr.io",
+		"https://ghcr.io/v1/":                 "ghcr.io",
+		"registry.example.com:5000":           "registry.example.com:5000",
+		"localhost:5000":                      "localhost:5000",
+		"123.dkr.ecr.eu-west-1.amazonaws.com": "123.dkr.ecr.eu-west-1.amazonaws.com",
+	}
+
+	for server, want := range cases {
+		if got := ServerHost(server); got != want {
+			t.Errorf("%s want: %s, got: %s", server, want, got)
+		}
+	}
+}
+
+func Test_ServerHost_MatchesRegistryDomain(t *testing.T) {
+	for _, domain := range []string{"docker.io", "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com", "ghcr.io", "Registry.Example.com:5000"} {
+		registry := parseRegistry(t, domain+"/ofctest/")
+		if got := ServerHost(domain); got != registry.Domain() {
+			t.Errorf("%s want the domain of the registry: %s, got: %s", domain, registry.Domain(), got)
+		}
+	}
+}
+
+func Test_FindRegistryAuth_DockerHubAliases(t *testing.T) {
+	file := []byte(`{"auths": {"registry.hub.docker.com": {"auth": "Zm9vOmJhcgo="}}}`)
+
+	registryAuth, err := FindRegistryAuth(parseRegistry(t, "registry.hub.docker.com/ofctest/"), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registryAuth.Host != DockerHubHost {
+		t.Errorf("want host: %s, got: %s", DockerHubHost, registryAuth.Host)
+	}
+}
+
+func Test_FindRegistryAuth_AuthsKeyVariants(t *testing.T) {
+	cases := []struct {
+		key      string
+		registry string
+	}{
+		{key: "ghcr.io", registry: "ghcr.io/openfaas/"},
+		{key: "https://ghcr.io", registry: "ghcr.io/openfaas/"},
+		{key: "https://ghcr.io/v1/", registry: "ghcr.io/openfaas/"},
+		{key: "registry.example.com:5000", registry: "registry.example.com:5000/team/"},
+		{key: "docker.io", registry: "ofctest/"},
+	}
+
+	for _, c := range cases {
+		file := []byte(fmt.Sprintf(`{"auths": {"%s": {"auth": "Zm9vOmJhcgo="}}}`, c.key))
+		registryAuth, err := FindRegistryAuth(parseRegistry(t, c.registry), file)
+		if err != nil {
+			t.Errorf("%s with key %s, want no error, got: %s", c.registry, c.key, err.Error())
+			continue
+		}
+		if registryAuth.Key != c.key {
+			t.Errorf("%s want key: %s, got: %s", c.registry, c.key, registryAuth.Key)
+		}
+	}
+}
+
+func Test_FindRegistryAuth_CredHelpers(t *testing.T) {
+	file := []byte(`{"auths": {}, "credsStore": "ecr-login",
+		"credHelpers": {"123456789012.dkr.ecr.eu-west-1.amazonaws.com": "ecr-login"}}`)
+
+	registryAuth, err := FindRegistryAuth(parseRegistry(t, "123456789012.dkr.ecr.eu-west-1.amazonaws.com/"), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "credential helper docker-credential-ecr-login"
+	if registryAuth.Source() != want {
+		t.Errorf("want: %s, got: %s", want, registryAuth.Source())
+	}
+}
+
+func Test_FindRegistryAuth_HelperNotInCluster(t *testing.T) {
+	file := []byte(`{"auths": {"ghcr.io": {}}, "credHelpers": {"ghcr.io": "desktop"}}`)
+
+	_, err := FindRegistryAuth(parseRegistry(t, "ghcr.io/openfaas/"), file)
+	want := "credentials for ghcr.io are held by docker-credential-desktop, which is not available in the cluster. Use ofc-bootstrap registry-login to write them to the file instead"
+	if err == nil || err.Error() != want {
+		t.Errorf("want error: %s, got: %v", want, err)
+	}
+}
+
+func Test_FindRegistryAuth_CredsStoreBeforeAuths(t *testing.T) {
+	file := []byte(`{"auths": {"ghcr.io": {"auth": "Zm9vOmJhcgo="}}, "credsStore": "desktop"}`)
+
+	_, err := FindRegistryAuth(parseRegistry(t, "ghcr.io/openfaas/"), file)
+	want := "credentials for ghcr.io are held by docker-credential-desktop, which is not available in the cluster"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("want the inline auth shadowed by the credsStore, got: %v", err)
+	}
+
+	file = []byte(`{"auths": {"ghcr.io": {"auth": "Zm9vOmJhcgo="}}, "credHelpers": {"ghcr.io": "ecr-login"}, "credsStore": "desktop"}`)
+	registryAuth, err := FindRegistryAuth(parseRegistry(t, "ghcr.io/openfaas/"), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registryAuth.Helper != "ecr-login" {
+		t.Errorf("want the credHelpers entry before the credsStore, got: %s", registryAuth.Source())
+	}
+}